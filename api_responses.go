@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/deadpyxel/curator/internal/database"
@@ -25,25 +27,65 @@ func dbUserToUser(dbUser database.User) User {
 	}
 }
 
+// Feed statuses, so clients can spot broken subscriptions
+const (
+	feedStatusOK       = "ok"
+	feedStatusFailing  = "failing"
+	feedStatusDisabled = "disabled"
+)
+
 type Feed struct {
-	ID            uuid.UUID `json:"id"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	Name          string    `json:"namme"`
-	URL           string    `json:"url"`
-	UserID        uuid.UUID `json:"user_id"`
-	LastFetchedAt time.Time `json:"last_fetched_at"`
+	ID                  uuid.UUID `json:"id"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	Name                string    `json:"namme"`
+	URL                 string    `json:"url"`
+	UserID              uuid.UUID `json:"user_id"`
+	LastFetchedAt       time.Time `json:"last_fetched_at"`
+	Status              string    `json:"status"`
+	ConsecutiveFailures int32     `json:"consecutive_failures"`
+	LastError           *string   `json:"last_error"` // ensure this field is nullable in JSON
+	LastSuccessAt       time.Time `json:"last_success_at"`
+	NextFetchAt         time.Time `json:"next_fetch_at"`
+	DisabledAt          time.Time `json:"disabled_at"`
+	// Channel metadata, refreshed on every successful fetch. Fields are null until the first one
+	Title       *string `json:"title"`
+	SiteURL     *string `json:"site_url"`
+	Description *string `json:"description"`
+	Language    *string `json:"language"`
+	ImageURL    *string `json:"image_url"`
 }
 
 func dbFeedToFeed(dbFeed database.Feed) Feed {
+	status := feedStatusOK
+	if dbFeed.DisabledAt.Valid {
+		status = feedStatusDisabled
+	} else if dbFeed.ConsecutiveFailures > 0 {
+		status = feedStatusFailing
+	}
+	var lastError *string
+	if dbFeed.LastError.Valid {
+		lastError = &dbFeed.LastError.String
+	}
 	return Feed{
-		ID:            dbFeed.ID,
-		CreatedAt:     dbFeed.CreatedAt,
-		UpdatedAt:     dbFeed.UpdatedAt,
-		Name:          dbFeed.Name,
-		URL:           dbFeed.Url,
-		UserID:        dbFeed.UserID,
-		LastFetchedAt: dbFeed.LastFetchedAt.Time,
+		ID:                  dbFeed.ID,
+		CreatedAt:           dbFeed.CreatedAt,
+		UpdatedAt:           dbFeed.UpdatedAt,
+		Name:                dbFeed.Name,
+		URL:                 dbFeed.Url,
+		UserID:              dbFeed.UserID,
+		LastFetchedAt:       dbFeed.LastFetchedAt.Time,
+		Status:              status,
+		ConsecutiveFailures: dbFeed.ConsecutiveFailures,
+		LastError:           lastError,
+		LastSuccessAt:       dbFeed.LastSuccessAt.Time,
+		NextFetchAt:         dbFeed.NextFetchAt.Time,
+		DisabledAt:          dbFeed.DisabledAt.Time,
+		Title:               nullStringToPointer(dbFeed.Title),
+		SiteURL:             nullStringToPointer(dbFeed.SiteUrl),
+		Description:         nullStringToPointer(dbFeed.Description),
+		Language:            nullStringToPointer(dbFeed.Language),
+		ImageURL:            nullStringToPointer(dbFeed.ImageUrl),
 	}
 }
 
@@ -90,6 +132,13 @@ type Post struct {
 	Description *string   `json:"description"` // ensure this field is nullable in JSON
 	PublishedAt time.Time `json:"published_at"`
 	FeedID      uuid.UUID `json:"feed_id"`
+	// PublishedAtEstimated is set when the feed did not provide a valid date and the fetch time was used instead
+	PublishedAtEstimated bool        `json:"published_at_estimated"`
+	Content              *string     `json:"content"` // full article, when provided by the feed
+	Excerpt              *string     `json:"excerpt"` // plain text summary of the post
+	Enclosures           []Enclosure `json:"enclosures"`
+	Authors              []string    `json:"authors"`
+	Categories           []string    `json:"categories"`
 }
 
 func dbPostToPost(dbPost database.Post) Post {
@@ -97,22 +146,191 @@ func dbPostToPost(dbPost database.Post) Post {
 	if dbPost.Description.Valid {
 		desc = &dbPost.Description.String
 	}
+	var content *string
+	if dbPost.Content.Valid {
+		content = &dbPost.Content.String
+	}
+	var excerpt *string
+	if dbPost.Excerpt.Valid {
+		excerpt = &dbPost.Excerpt.String
+	}
 	return Post{
-		ID:          dbPost.ID,
-		CreatedAt:   dbPost.CreatedAt,
-		UpdatedAt:   dbPost.UpdatedAt,
-		Title:       dbPost.Title,
-		Url:         dbPost.Url,
-		Description: desc,
-		PublishedAt: dbPost.PublishedAt,
-		FeedID:      dbPost.FeedID,
+		ID:                   dbPost.ID,
+		CreatedAt:            dbPost.CreatedAt,
+		UpdatedAt:            dbPost.UpdatedAt,
+		Title:                dbPost.Title,
+		Url:                  dbPost.Url,
+		Description:          desc,
+		PublishedAt:          dbPost.PublishedAt,
+		FeedID:               dbPost.FeedID,
+		PublishedAtEstimated: dbPost.PublishedAtEstimated,
+		Content:              content,
+		Excerpt:              excerpt,
+		Enclosures:           []Enclosure{},
+		Authors:              []string{},
+		Categories:           []string{},
 	}
 }
 
-func dbPostsToPosts(dbPosts []database.Post) []Post {
+// postMetadata holds the rows stored alongside a list of posts, loaded in bulk instead of once per post.
+type postMetadata struct {
+	Enclosures []database.Enclosure
+	Authors    []database.GetAuthorsByPostsRow
+	Categories []database.GetCategoriesByPostsRow
+}
+
+// dbPostsToPosts converts the posts, attaching to each of them their enclosures, authors and categories.
+func dbPostsToPosts(dbPosts []database.Post, metadata postMetadata) []Post {
 	posts := []Post{}
+	index := map[uuid.UUID]int{}
 	for _, dbPost := range dbPosts {
+		index[dbPost.ID] = len(posts)
 		posts = append(posts, dbPostToPost(dbPost))
 	}
+
+	for _, dbEnclosure := range metadata.Enclosures {
+		if i, ok := index[dbEnclosure.PostID]; ok {
+			posts[i].Enclosures = append(posts[i].Enclosures, dbEnclosureToEnclosure(dbEnclosure))
+		}
+	}
+	for _, author := range metadata.Authors {
+		if i, ok := index[author.PostID]; ok {
+			posts[i].Authors = append(posts[i].Authors, author.Name)
+		}
+	}
+	for _, category := range metadata.Categories {
+		if i, ok := index[category.PostID]; ok {
+			posts[i].Categories = append(posts[i].Categories, category.Name)
+		}
+	}
 	return posts
 }
+
+// Enclosure is a media file attached to a post, like a podcast episode.
+// Length is in bytes and both it and the duration are null when the feed did not provide them.
+type Enclosure struct {
+	Url             string  `json:"url"`
+	MimeType        *string `json:"mime_type"`
+	Length          *int64  `json:"length"`
+	DurationSeconds *int32  `json:"duration_seconds"`
+}
+
+func dbEnclosureToEnclosure(dbEnclosure database.Enclosure) Enclosure {
+	enclosure := Enclosure{Url: dbEnclosure.Url}
+	if dbEnclosure.MimeType.Valid {
+		enclosure.MimeType = &dbEnclosure.MimeType.String
+	}
+	if dbEnclosure.Length.Valid {
+		enclosure.Length = &dbEnclosure.Length.Int64
+	}
+	if dbEnclosure.DurationSeconds.Valid {
+		enclosure.DurationSeconds = &dbEnclosure.DurationSeconds.Int32
+	}
+	return enclosure
+}
+
+// PostRevision is a previous version of a post, replaced by an upstream edit at CreatedAt.
+type PostRevision struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	PostID      uuid.UUID `json:"post_id"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description *string   `json:"description"`
+	Content     *string   `json:"content"`
+}
+
+func dbPostRevisionToPostRevision(dbRevision database.PostRevision) PostRevision {
+	revision := PostRevision{
+		ID:        dbRevision.ID,
+		CreatedAt: dbRevision.CreatedAt,
+		PostID:    dbRevision.PostID,
+		Title:     dbRevision.Title,
+		Url:       dbRevision.Url,
+	}
+	if dbRevision.Description.Valid {
+		revision.Description = &dbRevision.Description.String
+	}
+	if dbRevision.Content.Valid {
+		revision.Content = &dbRevision.Content.String
+	}
+	return revision
+}
+
+func dbPostRevisionsToPostRevisions(dbRevisions []database.PostRevision) []PostRevision {
+	revisions := []PostRevision{}
+	for _, dbRevision := range dbRevisions {
+		revisions = append(revisions, dbPostRevisionToPostRevision(dbRevision))
+	}
+	return revisions
+}
+
+// nullStringToPointer converts a nullable database string into a pointer, so null values are kept in JSON.
+func nullStringToPointer(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+// FeedRefresh reports the outcome of fetching a feed on demand.
+type FeedRefresh struct {
+	NotModified  bool     `json:"not_modified"`
+	NewPosts     int      `json:"new_posts"`
+	UpdatedPosts int      `json:"updated_posts"`
+	Errors       []string `json:"errors"`
+}
+
+func fetchSummaryToFeedRefresh(summary fetchSummary, fetchErr error) FeedRefresh {
+	errors := []string{}
+	if fetchErr != nil {
+		errors = append(errors, fmt.Sprintf("Could not fetch feed: %v", fetchErr))
+	}
+	errors = append(errors, summary.Errors...)
+	return FeedRefresh{
+		NotModified:  summary.NotModified,
+		NewPosts:     summary.NewPosts,
+		UpdatedPosts: summary.UpdatedPosts,
+		Errors:       errors,
+	}
+}
+
+// FeedFetch is a fetch attempt of a feed. StatusCode is null when the server could not be reached.
+type FeedFetch struct {
+	ID           uuid.UUID `json:"id"`
+	FeedID       uuid.UUID `json:"feed_id"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	StatusCode   *int32    `json:"status_code"`
+	BodyBytes    int64     `json:"body_bytes"`
+	ItemsSeen    int32     `json:"items_seen"`
+	PostsCreated int32     `json:"posts_created"`
+	PostsUpdated int32     `json:"posts_updated"`
+	Error        *string   `json:"error"`
+}
+
+func dbFeedFetchToFeedFetch(dbFetch database.FeedFetch) FeedFetch {
+	fetch := FeedFetch{
+		ID:           dbFetch.ID,
+		FeedID:       dbFetch.FeedID,
+		StartedAt:    dbFetch.StartedAt,
+		FinishedAt:   dbFetch.FinishedAt,
+		BodyBytes:    dbFetch.BodyBytes,
+		ItemsSeen:    dbFetch.ItemsSeen,
+		PostsCreated: dbFetch.PostsCreated,
+		PostsUpdated: dbFetch.PostsUpdated,
+		Error:        nullStringToPointer(dbFetch.Error),
+	}
+	if dbFetch.StatusCode.Valid {
+		fetch.StatusCode = &dbFetch.StatusCode.Int32
+	}
+	return fetch
+}
+
+func dbFeedFetchesToFeedFetches(dbFetches []database.FeedFetch) []FeedFetch {
+	fetches := []FeedFetch{}
+	for _, dbFetch := range dbFetches {
+		fetches = append(fetches, dbFeedFetchToFeedFetch(dbFetch))
+	}
+	return fetches
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+)
+
+type AtomFeed struct {
+	Title    string       `xml:"title"`
+	Subtitle string       `xml:"subtitle"`
+	Authors  []AtomPerson `xml:"author"`
+	Icon     string       `xml:"icon"`
+	Logo     string       `xml:"logo"`
+	Links    []AtomLink   `xml:"link"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+type AtomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	Summary    AtomText       `xml:"summary"`
+	Content    AtomText       `xml:"content"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []AtomPerson   `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+}
+
+// String returns the textual value of an Atom text construct.
+// XHTML content is wrapped in markup, so the raw inner XML is returned in that case.
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// alternateLink returns the href of the first link with rel="alternate".
+// Links without a rel attribute are alternate links by definition. Other relations, like enclosures
+// or replies, do not point to the entry itself, so no link is returned when only those are present.
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// enclosureLinks returns the links with rel="enclosure", which point to media files like podcast episodes.
+func enclosureLinks(links []AtomLink) []RSSEnclosure {
+	enclosures := []RSSEnclosure{}
+	for _, link := range links {
+		if link.Rel == "enclosure" {
+			enclosures = append(enclosures, RSSEnclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+		}
+	}
+	return enclosures
+}
+
+// personNames returns the names of the people, skipping the ones without a name.
+func personNames(people []AtomPerson) []string {
+	names := []string{}
+	for _, person := range people {
+		if name := strings.TrimSpace(person.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// categoryNames returns the human readable label of each category, falling back to its term.
+func categoryNames(categories []AtomCategory) []string {
+	names := []string{}
+	for _, category := range categories {
+		name := category.Label
+		if name == "" {
+			name = category.Term
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// toRSSFeed maps the Atom feed into the RSSFeed representation used by the crawler.
+func (f AtomFeed) toRSSFeed() RSSFeed {
+	items := make([]RSSFeedItem, 0, len(f.Entries))
+	for _, entry := range f.Entries {
+		// Summary is optional in Atom. Content is not copied into the description when it is missing,
+		// as that would store the same body twice, while the excerpt is derived from the content anyway
+		description := entry.Summary.String()
+		// Published is optional as well, while updated is always present
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+		// Entries without authors inherit the feed authors
+		authors := personNames(entry.Authors)
+		if len(authors) == 0 {
+			authors = personNames(f.Authors)
+		}
+		items = append(items, RSSFeedItem{
+			GUID:        strings.TrimSpace(entry.ID),
+			Title:       strings.TrimSpace(entry.Title),
+			Link:        alternateLink(entry.Links),
+			Description: description,
+			PubDate:     strings.TrimSpace(pubDate),
+			Author:      strings.Join(authors, ", "),
+			Creators:    authors,
+			Categories:  categoryNames(entry.Categories),
+			Content:     entry.Content.String(),
+			Enclosures:  enclosureLinks(entry.Links),
+		})
+	}
+
+	// The icon is square and meant for small displays, like a favicon, so it is preferred over the logo
+	image := strings.TrimSpace(f.Icon)
+	if image == "" {
+		image = strings.TrimSpace(f.Logo)
+	}
+
+	return RSSFeed{
+		Channel: RSSChannel{
+			Title:       strings.TrimSpace(f.Title),
+			AtomLinks:   f.Links,
+			Link:        alternateLink(f.Links),
+			Description: strings.TrimSpace(f.Subtitle),
+			Image:       RSSImage{URL: image},
+			Item:        items,
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var errUnknownDateFormat = errors.New("unknown date format")
+
+// dateLayouts is the catalogue of layouts seen in real world feeds, tried in order.
+// Day names and named time zones are normalised before parsing, so layouts only need to deal with numeric offsets.
+var dateLayouts = []string{
+	// RFC 1123 / RFC 822 variants, used by RSS
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05 -0700",
+	"January 2 2006 15:04:05 -0700",
+	"Jan 2 2006",
+	"January 2 2006",
+	// ISO 8601 / RFC 3339 variants, used by Atom and JSON Feed
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	// ANSIC, UnixDate and RubyDate, as produced by common programming languages
+	"Jan 2 15:04:05 2006",
+	"Jan 2 15:04:05 -0700 2006",
+}
+
+// zoneOffsets maps the named time zones commonly found in feeds to their numeric offsets.
+// Go only resolves zone abbreviations known to the local system, so they are replaced before parsing.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"IST":  "+0530",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"HKT":  "+0800",
+	"AWST": "+0800",
+	"ACST": "+0930",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+	"BRT":  "-0300",
+	"ART":  "-0300",
+}
+
+// monthNames maps non-English month names and abbreviations to the English abbreviations understood by time.Parse.
+var monthNames = map[string]string{
+	// Spanish
+	"ene": "Jan", "enero": "Jan", "febrero": "Feb", "marzo": "Mar", "abr": "Apr", "abril": "Apr", "mayo": "May",
+	"junio": "Jun", "julio": "Jul", "ago": "Aug", "agosto": "Aug", "septiembre": "Sep", "setiembre": "Sep",
+	"octubre": "Oct", "noviembre": "Nov", "dic": "Dec", "diciembre": "Dec",
+	// Portuguese
+	"fev": "Feb", "fevereiro": "Feb", "janeiro": "Jan", "março": "Mar", "marco": "Mar", "mai": "May", "maio": "May",
+	"junho": "Jun", "julho": "Jul", "set": "Sep", "setembro": "Sep", "out": "Oct", "outubro": "Oct",
+	"novembro": "Nov", "dez": "Dec", "dezembro": "Dec",
+	// French
+	"janv": "Jan", "janvier": "Jan", "févr": "Feb", "fevr": "Feb", "février": "Feb", "mars": "Mar", "avr": "Apr",
+	"avril": "Apr", "juin": "Jun", "juil": "Jul", "juillet": "Jul", "août": "Aug", "aout": "Aug", "sept": "Sep",
+	"septembre": "Sep", "octobre": "Oct", "novembre": "Nov", "déc": "Dec", "decembre": "Dec", "décembre": "Dec",
+	// German
+	"januar": "Jan", "jän": "Jan", "februar": "Feb", "mär": "Mar", "märz": "Mar", "juni": "Jun", "juli": "Jul",
+	"okt": "Oct", "oktober": "Oct", "dezember": "Dec",
+	// Italian
+	"gen": "Jan", "gennaio": "Jan", "febbraio": "Feb", "aprile": "Apr", "mag": "May", "maggio": "May",
+	"giu": "Jun", "giugno": "Jun", "lug": "Jul", "luglio": "Jul", "settembre": "Sep", "ott": "Oct",
+	"ottobre": "Oct", "dicembre": "Dec",
+	// Dutch
+	"mrt": "Mar", "mei": "May",
+}
+
+// leadingDayName matches a day name followed by a comma at the start of a date, in any language.
+var leadingDayName = regexp.MustCompile(`^[^\d,]+,\s*`)
+
+// isMonthName reports if the value is an English month name or abbreviation.
+func isMonthName(value string) bool {
+	for _, layout := range []string{"Jan", "January"} {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isWord reports if the value is made only of letters.
+func isWord(value string) bool {
+	for _, r := range value {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// normaliseDate prepares a date string for parsing.
+// It removes the day name (which is redundant and often localised), commas and extra whitespace,
+// and replaces named time zones and non-English month names with values understood by time.Parse.
+func normaliseDate(value string) string {
+	value = leadingDayName.ReplaceAllString(strings.TrimSpace(value), "")
+	value = strings.ReplaceAll(value, ",", " ")
+
+	fields := strings.Fields(value)
+	for i, field := range fields {
+		if offset, ok := zoneOffsets[strings.ToUpper(field)]; ok && i > 0 {
+			fields[i] = offset
+			continue
+		}
+		if month, ok := monthNames[strings.TrimSuffix(strings.ToLower(field), ".")]; ok {
+			fields[i] = month
+		}
+	}
+	// Day names without a trailing comma are dropped as well, as long as they are not the month
+	if len(fields) > 1 && isWord(fields[0]) && !isMonthName(fields[0]) {
+		fields = fields[1:]
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseDate parses a date using the catalogue of known layouts and returns it in UTC.
+// Dates without time zone information are assumed to be in UTC.
+func parseDate(value string) (time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return time.Time{}, errUnknownDateFormat
+	}
+
+	normalised := normaliseDate(value)
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, normalised)
+		if err == nil {
+			return date.UTC(), nil
+		}
+	}
+	return time.Time{}, errUnknownDateFormat
+}
+
+// parsePublishedDate parses the publication date of a feed item.
+// When the date is missing or can't be parsed, fallback is returned instead and estimated is set to true,
+// so the item is still stored and clients can tell its date is not reliable.
+func parsePublishedDate(value string, fallback time.Time) (date time.Time, estimated bool) {
+	date, err := parseDate(value)
+	if err != nil {
+		return fallback.UTC(), true
+	}
+	return date, false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+	}{
+		{"RFC1123Z", "Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"RFC1123 with GMT", "Mon, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"RFC1123 with named US zone", "Tue, 10 Jun 2003 04:00:00 EDT", time.Date(2003, 6, 10, 8, 0, 0, 0, time.UTC)},
+		{"RFC1123 with named European zone", "Wed, 15 Mar 2023 10:00:00 CET", time.Date(2023, 3, 15, 9, 0, 0, 0, time.UTC)},
+		{"RFC822 two digit year", "02 Jan 06 15:04 -0700", time.Date(2006, 1, 2, 22, 4, 0, 0, time.UTC)},
+		{"RFC822 with day name", "Mon, 02 Jan 06 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Single digit day", "Sat, 7 Sep 2024 08:30:00 +0200", time.Date(2024, 9, 7, 6, 30, 0, 0, time.UTC)},
+		{"Missing seconds", "Mon, 02 Jan 2006 15:04 +0000", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		{"Missing day name", "02 Jan 2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Day name without comma", "Mon 02 Jan 2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Full month name", "Monday, 02 January 2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Offset with colon", "Mon, 02 Jan 2006 15:04:05 +01:00", time.Date(2006, 1, 2, 14, 4, 5, 0, time.UTC)},
+		{"Missing time zone", "Mon, 02 Jan 2006 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"Extra whitespace", "  Mon,  02 Jan  2006 15:04:05   +0000 ", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"US style", "January 2, 2006 15:04:05 -0500", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"Date only", "Jan 2, 2006", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"Spanish day name", "Lun, 02 Ene 2006 15:04:05 +0000", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"German day name", "Mi, 15 Okt 2025 12:00:00 +0200", time.Date(2025, 10, 15, 10, 0, 0, 0, time.UTC)},
+		{"French day name", "mardi, 3 févr. 2015 09:00:00 +0100", time.Date(2015, 2, 3, 8, 0, 0, 0, time.UTC)},
+		{"Portuguese day name", "Sex, 20 Set 2024 18:00:00 -0300", time.Date(2024, 9, 20, 21, 0, 0, 0, time.UTC)},
+		{"RFC3339", "2024-01-02T10:00:00Z", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"RFC3339 with offset", "2024-01-02T10:00:00+02:00", time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)},
+		{"RFC3339 with fraction", "2024-01-02T10:00:00.123456Z", time.Date(2024, 1, 2, 10, 0, 0, 123456000, time.UTC)},
+		{"ISO 8601 without seconds", "2024-01-02T10:00+02:00", time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)},
+		{"ISO 8601 compact offset", "2024-01-02T10:00:00+0200", time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC)},
+		{"ISO 8601 without zone", "2024-01-02T10:00:00", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"ISO 8601 with space", "2024-01-02 10:00:00 +0000", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"ISO 8601 date only", "2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"ANSIC", "Mon Jan  2 15:04:05 2006", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"UnixDate", "Mon Jan  2 15:04:05 PST 2006", time.Date(2006, 1, 2, 23, 4, 5, 0, time.UTC)},
+		{"RubyDate", "Mon Jan 02 15:04:05 -0700 2006", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, err := parseDate(tt.value)
+			if err != nil {
+				t.Fatalf("Expected no error parsing %q, got %v", tt.value, err)
+			}
+
+			if !date.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, date)
+			}
+			if date.Location() != time.UTC {
+				t.Errorf("Expected date in UTC, got %v", date.Location())
+			}
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	tests := []string{"", "   ", "yesterday", "32 Foo 2024", "2024-13-45T99:00:00Z"}
+
+	for _, value := range tests {
+		t.Run(value, func(t *testing.T) {
+			_, err := parseDate(value)
+			if err == nil {
+				t.Errorf("Expected error parsing %q, got none", value)
+			}
+		})
+	}
+}
+
+func TestParsePublishedDate(t *testing.T) {
+	fallback := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Valid date is not estimated", func(t *testing.T) {
+		date, estimated := parsePublishedDate("2024-01-02T10:00:00Z", fallback)
+		if estimated {
+			t.Error("Expected date not to be estimated")
+		}
+		if !date.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected parsed date, got %v", date)
+		}
+	})
+
+	t.Run("Invalid date falls back", func(t *testing.T) {
+		date, estimated := parsePublishedDate("not a date", fallback)
+		if !estimated {
+			t.Error("Expected date to be estimated")
+		}
+		if !date.Equal(fallback) {
+			t.Errorf("Expected fallback date %v, got %v", fallback, date)
+		}
+	})
+}
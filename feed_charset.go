@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var errUnsupportedCharset = errors.New("unsupported charset")
+
+// xmlEncodingPattern matches the encoding of an XML declaration, like <?xml version="1.0" encoding="ISO-8859-1"?>.
+var xmlEncodingPattern = regexp.MustCompile(`^\s*<\?xml[^>]*\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+)
+
+// newUTF8Reader returns a reader converting a feed document to UTF-8, so the rest of the parser does not have to deal
+// with encodings. prefix holds the first bytes of body, without consuming them.
+// The charset is taken from the byte order mark, then the Content-Type header and then the XML declaration.
+// A Content-Type claiming UTF-8 for a document that does not start with valid UTF-8 is ignored,
+// since servers often send a default charset.
+func newUTF8Reader(contentType string, prefix []byte, body *bufio.Reader) (io.Reader, error) {
+	switch {
+	case bytes.HasPrefix(prefix, utf8BOM):
+		_, err := body.Discard(len(utf8BOM))
+		return body, err
+	case bytes.HasPrefix(prefix, utf16BEBOM):
+		_, err := body.Discard(len(utf16BEBOM))
+		if err != nil {
+			return nil, err
+		}
+		return convertToUTF8("utf-16be", body)
+	case bytes.HasPrefix(prefix, utf16LEBOM):
+		_, err := body.Discard(len(utf16LEBOM))
+		if err != nil {
+			return nil, err
+		}
+		return convertToUTF8("utf-16le", body)
+	}
+
+	label := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		label = strings.TrimSpace(params["charset"])
+	}
+	if label == "" || (isUTF8Label(label) && !validUTF8Prefix(prefix)) {
+		if match := xmlEncodingPattern.FindSubmatch(prefix); match != nil {
+			label = string(match[1])
+		}
+	}
+	if label == "" || isUTF8Label(label) {
+		return body, nil
+	}
+	return convertToUTF8(label, body)
+}
+
+// convertToUTF8 returns a reader decoding body from the charset with the given label into UTF-8.
+func convertToUTF8(label string, body io.Reader) (io.Reader, error) {
+	encoding, _ := charset.Lookup(label)
+	if encoding == nil {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedCharset, label)
+	}
+	return encoding.NewDecoder().Reader(body), nil
+}
+
+// validUTF8Prefix reports if the start of a document is valid UTF-8.
+// The prefix can end in the middle of a character, which is not an encoding error.
+func validUTF8Prefix(prefix []byte) bool {
+	for i := 1; i <= utf8.UTFMax && i <= len(prefix); i++ {
+		if utf8.RuneStart(prefix[len(prefix)-i]) {
+			if !utf8.FullRune(prefix[len(prefix)-i:]) {
+				prefix = prefix[:len(prefix)-i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(prefix)
+}
+
+func isUTF8Label(label string) bool {
+	return strings.EqualFold(label, "utf-8") || strings.EqualFold(label, "utf8")
+}
+
+// newUTF8Decoder returns an XML decoder for a document already converted to UTF-8 by newUTF8Reader.
+// The encoding in the XML declaration no longer describes the data at that point, so it is ignored.
+func newUTF8Decoder(document io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(document)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseFeedCharsets(t *testing.T) {
+	rssWithTitle := func(declaration string, title []byte) []byte {
+		document := []byte(declaration + `<rss><channel><title>`)
+		document = append(document, title...)
+		return append(document, []byte(`</title></channel></rss>`)...)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		document    []byte
+		expected    string
+	}{
+		{
+			name:        "ISO-8859-1 declaration",
+			contentType: "application/rss+xml",
+			document:    rssWithTitle(`<?xml version="1.0" encoding="ISO-8859-1"?>`, []byte("Caf\xe9")),
+			expected:    "Café",
+		},
+		{
+			name:        "Windows-1252 Content-Type charset",
+			contentType: "application/rss+xml; charset=windows-1252",
+			document:    rssWithTitle("", []byte("\x80 10 \x96 it\x92s")),
+			expected:    "€ 10 – it’s",
+		},
+		{
+			name:        "Shift_JIS declaration",
+			contentType: "text/xml",
+			document:    rssWithTitle(`<?xml version="1.0" encoding="Shift_JIS"?>`, []byte("\x93\xfa\x96\x7b")),
+			expected:    "日本",
+		},
+		{
+			name:        "Content-Type charset overrides the declaration",
+			contentType: "application/rss+xml; charset=utf-8",
+			document:    rssWithTitle(`<?xml version="1.0" encoding="ISO-8859-1"?>`, []byte("Café")),
+			expected:    "Café",
+		},
+		{
+			name:        "Wrong UTF-8 Content-Type charset",
+			contentType: "application/rss+xml; charset=utf-8",
+			document:    rssWithTitle(`<?xml version="1.0" encoding="ISO-8859-1"?>`, []byte("Caf\xe9")),
+			expected:    "Café",
+		},
+		{
+			name:        "UTF-8 byte order mark",
+			contentType: "application/rss+xml",
+			document:    append([]byte("\xef\xbb\xbf"), rssWithTitle(`<?xml version="1.0"?>`, []byte("Café"))...),
+			expected:    "Café",
+		},
+		{
+			name:        "UTF-16 byte order mark",
+			contentType: "application/rss+xml",
+			document:    []byte("\xff\xfe<\x00r\x00s\x00s\x00>\x00<\x00c\x00h\x00a\x00n\x00n\x00e\x00l\x00>\x00<\x00t\x00i\x00t\x00l\x00e\x00>\x00C\x00a\x00f\x00\xe9\x00<\x00/\x00t\x00i\x00t\x00l\x00e\x00>\x00<\x00/\x00c\x00h\x00a\x00n\x00n\x00e\x00l\x00>\x00<\x00/\x00r\x00s\x00s\x00>\x00"),
+			expected:    "Café",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, bytes.NewReader(tt.document))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if feed.Channel.Title != tt.expected {
+				t.Errorf("Expected title %q, got %q", tt.expected, feed.Channel.Title)
+			}
+		})
+	}
+}
+
+func TestParseFeedUnsupportedCharset(t *testing.T) {
+	document := []byte(`<?xml version="1.0" encoding="x-unknown"?><rss><channel><title>Feed</title></channel></rss>`)
+	_, err := parseFeed("application/rss+xml", bytes.NewReader(document))
+	if !errors.Is(err, errUnsupportedCharset) {
+		t.Errorf("Expected unsupported charset error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// feedMediaTypes are the link types advertised by web pages for their feeds.
+// Plain application/json is left out, as pages like WordPress ones use it to link to their REST API.
+var feedMediaTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// maxPageSize limits how much of a web page is read while looking for links.
+// Pages are only searched for links, so a truncated page is good enough.
+const maxPageSize = 2 << 20
+
+// commonFeedPaths are tried, in order, when a page does not advertise any feed.
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/feed.xml", "/atom.xml", "/index.xml", "/rss", "/feed.json"}
+
+// feedCandidate is a feed found while looking for feeds on a web page.
+type feedCandidate struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	// probe holds the result of fetching the feed, for candidates that were already fetched while looking for them
+	probe *feedResponse
+}
+
+// isHTMLPage reports if a response is a web page instead of a feed, using the content type and sniffing the body.
+func isHTMLPage(contentType string, data []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+		return true
+	}
+	return strings.HasPrefix(http.DetectContentType(data), "text/html")
+}
+
+// discoverFeeds fetches rawURL as a feed. Users often provide the address of a website instead,
+// so when rawURL points to a web page the feeds it links to are returned, without fetching the page again.
+// When the page has no feed links, a few common feed locations are probed instead.
+// isPage is false when rawURL holds a feed, in which case probe is the result of fetching it.
+func discoverFeeds(ctx context.Context, fetcher Fetcher, rawURL string) (probe feedResponse, isPage bool, candidates []feedCandidate, err error) {
+	probe, err = fetcher.Fetch(ctx, rawURL, cacheValidators{})
+	var page *webPageError
+	if !errors.As(err, &page) {
+		return probe, false, nil, err
+	}
+
+	// Relative links are resolved against the final page URL, after any redirects
+	candidates, err = feedLinks(page.URL, bytes.NewReader(page.Page))
+	if err != nil {
+		return feedResponse{}, true, nil, err
+	}
+	if len(candidates) > 0 {
+		return feedResponse{}, true, candidates, nil
+	}
+
+	return feedResponse{}, true, probeCommonFeedPaths(ctx, fetcher, page.URL), nil
+}
+
+// feedLinks parses an HTML page and returns the feeds advertised through <link rel="alternate"> tags.
+func feedLinks(pageURL *url.URL, page io.Reader) ([]feedCandidate, error) {
+	doc, err := html.Parse(page)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := pageURL
+	candidates := []feedCandidate{}
+	seen := map[string]bool{}
+
+	var visit func(node *html.Node)
+	visit = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			attrs := map[string]string{}
+			for _, attr := range node.Attr {
+				attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+			}
+
+			switch node.Data {
+			case "base":
+				if href, err := pageURL.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+					baseURL = href
+				}
+			case "link":
+				linkType := strings.ToLower(attrs["type"])
+				if hasToken(attrs["rel"], "alternate") && feedMediaTypes[linkType] && attrs["href"] != "" {
+					href, err := baseURL.Parse(attrs["href"])
+					if err == nil && !seen[href.String()] {
+						seen[href.String()] = true
+						candidates = append(candidates, feedCandidate{URL: href.String(), Title: attrs["title"], Type: linkType})
+					}
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(doc)
+
+	return candidates, nil
+}
+
+// hasToken reports if the space separated list contains token, ignoring case.
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeCommonFeedPaths fetches the usual feed locations of a site and returns the first one holding a valid feed.
+func probeCommonFeedPaths(ctx context.Context, fetcher Fetcher, siteURL *url.URL) []feedCandidate {
+	for _, path := range commonFeedPaths {
+		candidateURL := siteURL.ResolveReference(&url.URL{Path: path}).String()
+		resp, err := fetcher.Fetch(ctx, candidateURL, cacheValidators{})
+		if err != nil {
+			logger.Debug("No feed found at common path", "url", candidateURL, "error", err)
+			continue
+		}
+		return []feedCandidate{{URL: candidateURL, Title: resp.Feed.Channel.Title, probe: &resp}}
+	}
+	return []feedCandidate{}
+}
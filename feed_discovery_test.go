@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFeedLinks(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Blog</title>
+    <link rel="stylesheet" href="/style.css">
+    <link rel="alternate" type="application/rss+xml" title="RSS" href="/rss.xml">
+    <link rel="Alternate" type="application/atom+xml" title="Atom" href="https://example.com/atom.xml">
+    <link rel="alternate feed" type="application/feed+json" href="feed.json">
+    <link rel="alternate" type="text/html" hreflang="pt" href="/pt/">
+    <link rel="alternate" type="application/json" href="/wp-json/wp/v2/pages/2">
+    <link rel="alternate" type="application/rss+xml" href="/rss.xml">
+  </head>
+  <body></body>
+</html>`
+	pageURL, _ := url.Parse("https://example.com/blog/")
+
+	candidates, err := feedLinks(pageURL, strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []feedCandidate{
+		{URL: "https://example.com/rss.xml", Title: "RSS", Type: "application/rss+xml"},
+		{URL: "https://example.com/atom.xml", Title: "Atom", Type: "application/atom+xml"},
+		{URL: "https://example.com/blog/feed.json", Title: "", Type: "application/feed+json"},
+	}
+	if len(candidates) != len(expected) {
+		t.Fatalf("Expected %d candidates, got %d: %+v", len(expected), len(candidates), candidates)
+	}
+	for i := range expected {
+		if candidates[i] != expected[i] {
+			t.Errorf("Expected candidate %+v, got %+v", expected[i], candidates[i])
+		}
+	}
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>No links</title></head><body></body></html>`))
+	})
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssDocument))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	t.Run("Feed URL is not a page", func(t *testing.T) {
+		probe, isPage, _, err := discoverFeeds(context.Background(), fetcher, server.URL+"/rss.xml")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if isPage {
+			t.Error("Expected feed not to be detected as a page")
+		}
+		// The feed is parsed from the discovery response, so it is not downloaded twice
+		if probe.Feed.Channel.Title != "RSS Channel" {
+			t.Errorf("Expected the probed feed to be returned, got %+v", probe.Feed.Channel)
+		}
+	})
+
+	t.Run("Page without links falls back to common paths", func(t *testing.T) {
+		_, isPage, candidates, err := discoverFeeds(context.Background(), fetcher, server.URL+"/")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !isPage {
+			t.Error("Expected URL to be detected as a page")
+		}
+		if len(candidates) != 1 || candidates[0].URL != server.URL+"/rss.xml" {
+			t.Errorf("Expected the common feed path to be found, got %+v", candidates)
+		}
+		if candidates[0].Title != "RSS Channel" {
+			t.Errorf("Expected candidate title from the feed, got %q", candidates[0].Title)
+		}
+		if candidates[0].probe == nil {
+			t.Error("Expected the probed feed to be kept with the candidate")
+		}
+	})
+}
+
+func TestProbeCommonFeedPaths(t *testing.T) {
+	siteURL, _ := url.Parse("https://example.com/blog/")
+	fetcher := &fakeFetcher{feeds: map[string]RSSFeed{
+		"https://example.com/atom.xml":  {Channel: RSSChannel{Title: "Atom"}},
+		"https://example.com/feed.json": {Channel: RSSChannel{Title: "JSON"}},
+	}}
+
+	candidates := probeCommonFeedPaths(context.Background(), fetcher, siteURL)
+	if len(candidates) != 1 || candidates[0].URL != "https://example.com/atom.xml" || candidates[0].Title != "Atom" {
+		t.Errorf("Expected the first common path holding a feed, got %+v", candidates)
+	}
+	expectedRequests := []string{"https://example.com/feed", "https://example.com/rss.xml", "https://example.com/feed.xml", "https://example.com/atom.xml"}
+	if strings.Join(fetcher.requested, " ") != strings.Join(expectedRequests, " ") {
+		t.Errorf("Expected requests %q, got %q", expectedRequests, fetcher.requested)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/deadpyxel/curator/internal/database"
+	"golang.org/x/net/html"
+)
+
+// RSSImage is the channel image. Other feed formats map their icon or logo into it.
+type RSSImage struct {
+	URL string `xml:"url"`
+}
+
+// ITunesImage is the podcast artwork, used by podcast feeds without a channel image.
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// imageURL returns the URL of the channel image, falling back to the podcast artwork.
+func (channel RSSChannel) imageURL() string {
+	if image := strings.TrimSpace(channel.Image.URL); image != "" {
+		return image
+	}
+	return strings.TrimSpace(channel.ITunesImage.Href)
+}
+
+// resolveWebURL resolves rawURL against base, returning an empty string unless the result is an http(s) URL.
+func resolveWebURL(base *url.URL, rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return ""
+	}
+	resolved, err := base.Parse(rawURL)
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+	return resolved.String()
+}
+
+// faviconRetryInterval is how long the crawler waits before looking up the favicon of a site again, after finding none.
+const faviconRetryInterval = 7 * 24 * time.Hour
+
+// faviconLookupDue reports if the favicon of the feed site should be looked up at now.
+func faviconLookupDue(feed database.Feed, now time.Time) bool {
+	return !feed.FaviconCheckedAt.Valid || now.Sub(feed.FaviconCheckedAt.Time) >= faviconRetryInterval
+}
+
+// findFavicon looks up the icon of a website, for feeds that do not provide an image.
+// Icons advertised by the home page are preferred, falling back to /favicon.ico when it exists.
+// An empty string is returned when no icon is found. answered is false when the site could not be asked,
+// because of a network error, a busy host or a server error, so the lookup is worth trying again soon.
+func findFavicon(ctx context.Context, web *webClient, siteURL string) (icon string, answered bool) {
+	resp, err := web.get(ctx, siteURL)
+	if err != nil {
+		logger.Debug("Could not fetch site for favicon", "url", siteURL, "error", err)
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return "", false
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, min(maxPageSize, web.maxBodySize)))
+	if err == nil && resp.StatusCode == http.StatusOK && isHTMLPage(resp.Header.Get("Content-Type"), data) {
+		if icon, err := iconLink(resp.Request.URL, bytes.NewReader(data)); err == nil && icon != "" {
+			return icon, true
+		}
+	}
+
+	faviconURL := resp.Request.URL.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, faviconURL, nil)
+	if err != nil {
+		return "", true
+	}
+	faviconResp, err := web.do(req)
+	if err != nil {
+		logger.Debug("Could not check site favicon", "url", faviconURL, "error", err)
+		return "", false
+	}
+	faviconResp.Body.Close()
+	switch {
+	case faviconResp.StatusCode >= http.StatusInternalServerError:
+		return "", false
+	case faviconResp.StatusCode != http.StatusOK:
+		return "", true
+	}
+	return faviconURL, true
+}
+
+// iconLink parses an HTML page and returns the first icon advertised through <link rel="icon"> tags.
+func iconLink(pageURL *url.URL, page io.Reader) (string, error) {
+	doc, err := html.Parse(page)
+	if err != nil {
+		return "", err
+	}
+
+	var visit func(node *html.Node) string
+	visit = func(node *html.Node) string {
+		if node.Type == html.ElementNode && node.Data == "link" {
+			attrs := map[string]string{}
+			for _, attr := range node.Attr {
+				attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+			}
+			// "shortcut icon" is a legacy value still in wide use
+			if hasToken(attrs["rel"], "icon") || hasToken(attrs["rel"], "apple-touch-icon") {
+				if icon := resolveWebURL(pageURL, attrs["href"]); icon != "" {
+					return icon
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if icon := visit(child); icon != "" {
+				return icon
+			}
+		}
+		return ""
+	}
+	return visit(doc), nil
+}
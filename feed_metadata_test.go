@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deadpyxel/curator/internal/database"
+)
+
+func TestChannelImageURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		document    string
+		expected    string
+	}{
+		{
+			name:        "RSS channel image",
+			contentType: "application/rss+xml",
+			document:    `<rss><channel><image><url>https://example.com/logo.png</url></image></channel></rss>`,
+			expected:    "https://example.com/logo.png",
+		},
+		{
+			name:        "Podcast artwork",
+			contentType: "application/rss+xml",
+			document: `<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel>
+  <itunes:image href="https://example.com/artwork.jpg"/>
+</channel></rss>`,
+			expected: "https://example.com/artwork.jpg",
+		},
+		{
+			name:        "Atom icon",
+			contentType: "application/atom+xml",
+			document: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <logo>https://example.com/logo.png</logo>
+  <icon>https://example.com/icon.png</icon>
+</feed>`,
+			expected: "https://example.com/icon.png",
+		},
+		{
+			name:        "JSON Feed favicon",
+			contentType: "application/feed+json",
+			document:    `{"version": "https://jsonfeed.org/version/1.1", "favicon": "https://example.com/favicon.png", "items": []}`,
+			expected:    "https://example.com/favicon.png",
+		},
+		{
+			name:        "No image",
+			contentType: "application/rss+xml",
+			document:    `<rss><channel><title>Feed</title></channel></rss>`,
+			expected:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, strings.NewReader(tt.document))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if image := feed.Channel.imageURL(); image != tt.expected {
+				t.Errorf("Expected image %q, got %q", tt.expected, image)
+			}
+		})
+	}
+}
+
+func TestFindFavicon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/with-icon/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="Shortcut Icon" href="static/icon.png"></head></html>`))
+		case "/without-icon/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><title>Site</title></head></html>`))
+		case "/favicon.ico":
+			w.Header().Set("Content-Type", "image/x-icon")
+		case "/broken/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	web := newHTTPFetcher(defaultFetcherConfig()).webClient()
+
+	tests := []struct {
+		name             string
+		siteURL          string
+		expected         string
+		expectedAnswered bool
+	}{
+		{"Icon advertised by the page", server.URL + "/with-icon/", server.URL + "/with-icon/static/icon.png", true},
+		{"Fallback to favicon.ico", server.URL + "/without-icon/", server.URL + "/favicon.ico", true},
+		{"Server error", server.URL + "/broken/", "", false},
+		{"Unreachable site", "http://127.0.0.1:1/", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			icon, answered := findFavicon(context.Background(), web, tt.siteURL)
+			if icon != tt.expected || answered != tt.expectedAnswered {
+				t.Errorf("Expected (%q, %t), got (%q, %t)", tt.expected, tt.expectedAnswered, icon, answered)
+			}
+		})
+	}
+}
+
+func TestFaviconLookupDue(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		checkedAt sql.NullTime
+		expected  bool
+	}{
+		{"Never looked up", sql.NullTime{}, true},
+		{"Looked up recently", sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, false},
+		{"Looked up a while ago", sql.NullTime{Time: now.Add(-faviconRetryInterval), Valid: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if due := faviconLookupDue(database.Feed{FaviconCheckedAt: tt.checkedAt}, now); due != tt.expected {
+				t.Errorf("Expected lookup due to be %v, got %v", tt.expected, due)
+			}
+		})
+	}
+}
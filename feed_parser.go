@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+var errUnsupportedFeedFormat = errors.New("unsupported feed format")
+
+// sniffSize is how much of a document is inspected to detect its format and charset.
+const sniffSize = 1024
+
+// parseFeed detects the format of the feed document, using both the response content type and the start of the body,
+// and decodes it while streaming from body.
+// Every supported format is mapped into an RSSFeed, so the rest of the crawler only deals with a single representation.
+func parseFeed(contentType string, body io.Reader) (RSSFeed, error) {
+	raw := bufio.NewReaderSize(body, sniffSize)
+	prefix, err := raw.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return RSSFeed{}, err
+	}
+	utf8Body, err := newUTF8Reader(contentType, prefix, raw)
+	if err != nil {
+		return RSSFeed{}, err
+	}
+
+	document := bufio.NewReaderSize(utf8Body, sniffSize)
+	// Read errors are reported by the decoders below, so they can be ignored while sniffing
+	start, _ := document.Peek(sniffSize)
+	if isJSONFeed(contentType, start) {
+		jsonFeed := JSONFeed{}
+		err = json.NewDecoder(document).Decode(&jsonFeed)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		return jsonFeed.toRSSFeed(), nil
+	}
+
+	decoder := newUTF8Decoder(document)
+	root, err := xmlRootElement(decoder)
+	if err != nil {
+		return RSSFeed{}, err
+	}
+
+	switch {
+	case root.Name.Local == "rss":
+		rssFeed := RSSFeed{}
+		err = decoder.DecodeElement(&rssFeed, &root)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		return rssFeed, nil
+	case root.Name.Local == "feed" && root.Name.Space == atomNamespace:
+		atomFeed := AtomFeed{}
+		err = decoder.DecodeElement(&atomFeed, &root)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		return atomFeed.toRSSFeed(), nil
+	case root.Name.Local == "RDF" && root.Name.Space == rdfNamespace:
+		rdfFeed := RDFFeed{}
+		err = decoder.DecodeElement(&rdfFeed, &root)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		return rdfFeed.toRSSFeed(), nil
+	default:
+		return RSSFeed{}, fmt.Errorf("%w: root element <%s>", errUnsupportedFeedFormat, root.Name.Local)
+	}
+}
+
+// isJSONFeed reports if the document should be handled as a JSON Feed.
+// Servers often send JSON feeds with generic content types, so the body is sniffed as well.
+func isJSONFeed(contentType string, data []byte) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/feed+json" || mediaType == "application/json") {
+		return true
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// xmlRootElement reads tokens until the first element of an XML document, and returns it so it can be decoded.
+func xmlRootElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return xml.StartElement{}, fmt.Errorf("%w: no root element found", errUnsupportedFeedFormat)
+			}
+			return xml.StartElement{}, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const rssDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+  <channel>
+    <title>RSS Channel</title>
+    <link>https://example.com/</link>
+    <description>An RSS feed</description>
+    <item>
+      <guid isPermaLink="false">post-1</guid>
+      <title>First post</title>
+      <link>https://example.com/first</link>
+      <description>First description</description>
+      <content:encoded><![CDATA[<p>Full article</p>]]></content:encoded>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+const atomDocument = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom Feed</title>
+  <subtitle>An Atom feed</subtitle>
+  <link href="https://example.com/atom.xml" rel="self"/>
+  <link href="https://example.com/"/>
+  <updated>2024-01-02T10:00:00Z</updated>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>Release v1.0.0</title>
+    <link rel="replies" href="https://example.com/v1/comments"/>
+    <link rel="alternate" type="text/html" href="https://example.com/v1"/>
+    <content type="html">&lt;p&gt;Release notes&lt;/p&gt;</content>
+    <published>2024-01-01T10:00:00Z</published>
+    <updated>2024-01-02T10:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title>Release v1.1.0</title>
+    <link href="https://example.com/v1.1"/>
+    <summary>Summary text</summary>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div></content>
+    <updated>2024-01-03T10:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>urn:uuid:3</id>
+    <title>Episode 1</title>
+    <link rel="enclosure" type="audio/mpeg" href="https://example.com/episode-1.mp3"/>
+    <link rel="self" href="https://example.com/entries/3.xml"/>
+    <updated>2024-01-04T10:00:00Z</updated>
+  </entry>
+</feed>`
+
+const rdfDocument = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.org/rss">
+    <title>RDF Channel</title>
+    <link>https://example.org/</link>
+    <description>An RSS 1.0 feed</description>
+    <items>
+      <rdf:Seq>
+        <rdf:li rdf:resource="https://example.org/paper-1"/>
+      </rdf:Seq>
+    </items>
+  </channel>
+  <item rdf:about="https://example.org/paper-1">
+    <title>Paper 1</title>
+    <link>https://example.org/paper-1</link>
+    <description>Abstract</description>
+    <dc:date>2023-11-05T12:30:00+00:00</dc:date>
+    <dc:creator>A. Researcher</dc:creator>
+  </item>
+</rdf:RDF>`
+
+const jsonFeedDocument = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON Feed",
+  "home_page_url": "https://example.com/",
+  "authors": [{"name": "Feed Author"}],
+  "items": [
+    {
+      "id": "1",
+      "url": "https://example.com/json-first",
+      "title": "First JSON item",
+      "content_html": "<p>Hello</p>",
+      "date_published": "2024-02-01T08:00:00+01:00",
+      "authors": [{"name": "Jane"}, {"name": "John"}]
+    },
+    {
+      "id": 2,
+      "external_url": "https://other.example.com/article",
+      "title": "Second JSON item",
+      "summary": "Short summary",
+      "content_text": "if a<b and c>d then x & y\n\nSecond paragraph",
+      "date_modified": "2024-02-02T08:00:00Z"
+    }
+  ]
+}`
+
+func TestParseFeed(t *testing.T) {
+	t.Run("RSS 2.0 document", func(t *testing.T) {
+		feed, err := parseFeed("application/rss+xml", strings.NewReader(rssDocument))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if feed.Channel.Title != "RSS Channel" {
+			t.Errorf("Expected channel title %q, got %q", "RSS Channel", feed.Channel.Title)
+		}
+		if len(feed.Channel.Item) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(feed.Channel.Item))
+		}
+		if feed.Channel.Item[0].Link != "https://example.com/first" {
+			t.Errorf("Expected link %q, got %q", "https://example.com/first", feed.Channel.Item[0].Link)
+		}
+		if feed.Channel.Item[0].Content != "<p>Full article</p>" {
+			t.Errorf("Expected content from content:encoded, got %q", feed.Channel.Item[0].Content)
+		}
+		if feed.Channel.Item[0].GUID != "post-1" {
+			t.Errorf("Expected guid %q, got %q", "post-1", feed.Channel.Item[0].GUID)
+		}
+	})
+
+	t.Run("Atom 1.0 document", func(t *testing.T) {
+		feed, err := parseFeed("application/atom+xml", strings.NewReader(atomDocument))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if feed.Channel.Title != "Atom Feed" {
+			t.Errorf("Expected channel title %q, got %q", "Atom Feed", feed.Channel.Title)
+		}
+		if feed.Channel.Link != "https://example.com/" {
+			t.Errorf("Expected channel link %q, got %q", "https://example.com/", feed.Channel.Link)
+		}
+		if len(feed.Channel.Item) != 3 {
+			t.Fatalf("Expected 3 items, got %d", len(feed.Channel.Item))
+		}
+
+		first := feed.Channel.Item[0]
+		if first.Link != "https://example.com/v1" {
+			t.Errorf("Expected alternate link %q, got %q", "https://example.com/v1", first.Link)
+		}
+		if first.Description != "" {
+			t.Errorf("Expected no description without a summary, got %q", first.Description)
+		}
+		if first.Content != "<p>Release notes</p>" {
+			t.Errorf("Expected content, got %q", first.Content)
+		}
+		if first.PubDate != "2024-01-01T10:00:00Z" {
+			t.Errorf("Expected published date, got %q", first.PubDate)
+		}
+		if first.GUID != "urn:uuid:1" {
+			t.Errorf("Expected guid from entry id, got %q", first.GUID)
+		}
+
+		second := feed.Channel.Item[1]
+		if second.Description != "Summary text" {
+			t.Errorf("Expected description from summary, got %q", second.Description)
+		}
+		if second.Content != `<div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div>` {
+			t.Errorf("Expected XHTML content, got %q", second.Content)
+		}
+		if second.PubDate != "2024-01-03T10:00:00Z" {
+			t.Errorf("Expected updated date as fallback, got %q", second.PubDate)
+		}
+
+		third := feed.Channel.Item[2]
+		if third.Link != "" {
+			t.Errorf("Expected no link without an alternate link, got %q", third.Link)
+		}
+		if len(third.Enclosures) != 1 {
+			t.Errorf("Expected 1 enclosure, got %d", len(third.Enclosures))
+		}
+	})
+
+	t.Run("JSON Feed document", func(t *testing.T) {
+		// Sniffing the body should work even with a generic content type
+		for _, contentType := range []string{"application/feed+json", "text/plain; charset=utf-8"} {
+			feed, err := parseFeed(contentType, strings.NewReader(jsonFeedDocument))
+			if err != nil {
+				t.Fatalf("Expected no error for %q, got %v", contentType, err)
+			}
+
+			if feed.Channel.Link != "https://example.com/" {
+				t.Errorf("Expected channel link %q, got %q", "https://example.com/", feed.Channel.Link)
+			}
+			if len(feed.Channel.Item) != 2 {
+				t.Fatalf("Expected 2 items, got %d", len(feed.Channel.Item))
+			}
+
+			first := feed.Channel.Item[0]
+			if first.Description != "" {
+				t.Errorf("Expected content_html not to be copied into the description, got %q", first.Description)
+			}
+			if first.Content != "<p>Hello</p>" {
+				t.Errorf("Expected content from content_html, got %q", first.Content)
+			}
+			if first.Author != "Jane, John" {
+				t.Errorf("Expected item authors, got %q", first.Author)
+			}
+
+			second := feed.Channel.Item[1]
+			if second.GUID != "2" {
+				t.Errorf("Expected guid from numeric id, got %q", second.GUID)
+			}
+			if second.Link != "https://other.example.com/article" {
+				t.Errorf("Expected external URL as fallback, got %q", second.Link)
+			}
+			if second.Description != "Short summary" {
+				t.Errorf("Expected description from summary, got %q", second.Description)
+			}
+			if second.Content != "<p>if a&lt;b and c&gt;d then x &amp; y</p><p>Second paragraph</p>" {
+				t.Errorf("Expected escaped content from content_text, got %q", second.Content)
+			}
+			if second.PubDate != "2024-02-02T08:00:00Z" {
+				t.Errorf("Expected modified date as fallback, got %q", second.PubDate)
+			}
+			if second.Author != "Feed Author" {
+				t.Errorf("Expected feed author as fallback, got %q", second.Author)
+			}
+		}
+	})
+
+	t.Run("RSS 1.0 document", func(t *testing.T) {
+		feed, err := parseFeed("application/rdf+xml", strings.NewReader(rdfDocument))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if feed.Channel.Title != "RDF Channel" {
+			t.Errorf("Expected channel title %q, got %q", "RDF Channel", feed.Channel.Title)
+		}
+		if len(feed.Channel.Item) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(feed.Channel.Item))
+		}
+
+		item := feed.Channel.Item[0]
+		if item.Link != "https://example.org/paper-1" {
+			t.Errorf("Expected link %q, got %q", "https://example.org/paper-1", item.Link)
+		}
+		if item.PubDate != "2023-11-05T12:30:00+00:00" {
+			t.Errorf("Expected dc:date as publication date, got %q", item.PubDate)
+		}
+		if item.Author != "A. Researcher" {
+			t.Errorf("Expected dc:creator as author, got %q", item.Author)
+		}
+		if item.GUID != "https://example.org/paper-1" {
+			t.Errorf("Expected guid from rdf:about, got %q", item.GUID)
+		}
+	})
+
+	t.Run("Unsupported document", func(t *testing.T) {
+		_, err := parseFeed("text/html", strings.NewReader(`<html><body>Not a feed</body></html>`))
+		if !errors.Is(err, errUnsupportedFeedFormat) {
+			t.Errorf("Expected unsupported format error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFetchInterval = time.Hour
+	minFetchInterval     = 15 * time.Minute
+	maxFetchInterval     = 24 * time.Hour
+	// postingSampleSize is the number of recent items used to estimate how often a feed publishes
+	postingSampleSize = 10
+	// failureBackoffBase is the wait after the first failed fetch, doubled on every consecutive failure
+	failureBackoffBase = 5 * time.Minute
+)
+
+// fetchSchedule holds everything known about a feed after a fetch, and is used to decide when to fetch it again.
+type fetchSchedule struct {
+	PreviousInterval time.Duration
+	NewPosts         int
+	PubDates         []time.Time
+	TTL              time.Duration // channel <ttl>, how long the feed may be cached
+	CacheLifetime    time.Duration // from the Cache-Control or Expires response headers
+	SkipHours        map[int]bool
+	SkipDays         map[time.Weekday]bool
+}
+
+// postingFrequency estimates the average time between posts using the most recent publication dates.
+// It returns zero when there is not enough data for an estimate.
+func postingFrequency(pubDates []time.Time) time.Duration {
+	if len(pubDates) < 2 {
+		return 0
+	}
+	dates := make([]time.Time, len(pubDates))
+	copy(dates, pubDates)
+	sort.Slice(dates, func(i, j int) bool { return dates[i].After(dates[j]) })
+	if len(dates) > postingSampleSize {
+		dates = dates[:postingSampleSize]
+	}
+
+	span := dates[0].Sub(dates[len(dates)-1])
+	return span / time.Duration(len(dates)-1)
+}
+
+// interval calculates the time to wait before fetching the feed again.
+// The feed is polled about twice per expected post, the interval widens when fetches bring nothing new
+// and never goes below what the publisher asked for through <ttl> or HTTP caching headers.
+func (s fetchSchedule) interval() time.Duration {
+	previous := s.PreviousInterval
+	if previous <= 0 {
+		previous = defaultFetchInterval
+	}
+
+	interval := previous
+	if frequency := postingFrequency(s.PubDates); frequency > 0 {
+		interval = frequency / 2
+	}
+
+	if s.NewPosts == 0 {
+		// Nothing new, so back off a little from the last interval
+		interval = max(interval, previous*3/2)
+	} else {
+		// The feed is active, never poll it less often than before
+		interval = min(interval, previous)
+	}
+
+	interval = max(interval, s.TTL, s.CacheLifetime)
+	return min(max(interval, minFetchInterval), maxFetchInterval)
+}
+
+// nextFetch returns when the feed should be fetched again and the interval used for it.
+// Times falling in the skip hours or skip days of the channel are moved to the next allowed hour.
+func (s fetchSchedule) nextFetch(now time.Time) (time.Time, time.Duration) {
+	interval := s.interval()
+	next := now.Add(interval).UTC()
+
+	// A week worth of hours is enough to find an allowed slot, unless every hour is skipped
+	for i := 0; i < 24*7 && s.skipped(next); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next, interval
+}
+
+// skipped reports if the channel asked not to be fetched at the given time. Skip hours and days are always in GMT.
+func (s fetchSchedule) skipped(t time.Time) bool {
+	t = t.UTC()
+	return s.SkipHours[t.Hour()] || s.SkipDays[t.Weekday()]
+}
+
+// failureBackoff returns how long to wait before retrying a feed that failed the given number of consecutive fetches.
+func failureBackoff(failures int32) time.Duration {
+	backoff := failureBackoffBase
+	for i := int32(1); i < failures && backoff < maxFetchInterval; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxFetchInterval)
+}
+
+// parseTTL parses the channel <ttl> element, which is expressed in minutes.
+func parseTTL(value string) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// parseSkipHours parses the hours listed in the channel <skipHours> element.
+func parseSkipHours(values []string) map[int]bool {
+	hours := map[int]bool{}
+	for _, value := range values {
+		hour, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || hour < 0 || hour > 24 {
+			continue
+		}
+		// Some feeds use 24 for midnight
+		hours[hour%24] = true
+	}
+	return hours
+}
+
+// parseSkipDays parses the days listed in the channel <skipDays> element.
+func parseSkipDays(values []string) map[time.Weekday]bool {
+	days := map[time.Weekday]bool{}
+	for _, value := range values {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(value), day.String()) {
+				days[day] = true
+			}
+		}
+	}
+	return days
+}
+
+// cacheLifetime returns how long a response may be cached according to its Cache-Control or Expires headers.
+func cacheLifetime(header http.Header, now time.Time) time.Duration {
+	cacheControl := header.Get("Cache-Control")
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, found := strings.CutPrefix(directive, "max-age="); found {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return 0
+			}
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	expires, err := http.ParseTime(header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	// Use the server clock when available, so clock skew doesn't affect the result
+	if date, err := http.ParseTime(header.Get("Date")); err == nil {
+		now = date
+	}
+	if lifetime := expires.Sub(now); lifetime > 0 {
+		return lifetime
+	}
+	return 0
+}
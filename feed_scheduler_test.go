@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// datesEvery returns n publication dates spaced by gap, starting from the most recent one.
+func datesEvery(n int, gap time.Duration) []time.Time {
+	start := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	dates := []time.Time{}
+	for i := 0; i < n; i++ {
+		dates = append(dates, start.Add(-time.Duration(i)*gap))
+	}
+	return dates
+}
+
+func TestFetchScheduleInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule fetchSchedule
+		expected time.Duration
+	}{
+		{
+			name:     "When there is no history uses the default interval widened",
+			schedule: fetchSchedule{},
+			expected: 90 * time.Minute,
+		},
+		{
+			name:     "When the feed posts often polls twice per post",
+			schedule: fetchSchedule{PreviousInterval: 4 * time.Hour, NewPosts: 3, PubDates: datesEvery(10, 2*time.Hour)},
+			expected: time.Hour,
+		},
+		{
+			name:     "When nothing new was found widens the interval",
+			schedule: fetchSchedule{PreviousInterval: 2 * time.Hour, PubDates: datesEvery(5, 30*time.Minute)},
+			expected: 3 * time.Hour,
+		},
+		{
+			name:     "When the feed posts rarely uses the maximum interval",
+			schedule: fetchSchedule{PreviousInterval: 20 * time.Hour, PubDates: datesEvery(3, 30*24*time.Hour)},
+			expected: maxFetchInterval,
+		},
+		{
+			name:     "When the feed posts very often uses the minimum interval",
+			schedule: fetchSchedule{PreviousInterval: time.Hour, NewPosts: 5, PubDates: datesEvery(10, time.Minute)},
+			expected: minFetchInterval,
+		},
+		{
+			name:     "When TTL is longer than the estimate honours the TTL",
+			schedule: fetchSchedule{PreviousInterval: time.Hour, NewPosts: 1, PubDates: datesEvery(10, time.Hour), TTL: 2 * time.Hour},
+			expected: 2 * time.Hour,
+		},
+		{
+			name:     "When cache lifetime is longer than the estimate honours the cache lifetime",
+			schedule: fetchSchedule{PreviousInterval: time.Hour, NewPosts: 1, CacheLifetime: 5 * time.Hour},
+			expected: 5 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interval := tt.schedule.interval()
+			if interval != tt.expected {
+				t.Errorf("Expected interval %v, got %v", tt.expected, interval)
+			}
+		})
+	}
+}
+
+func TestFetchScheduleNextFetchSkips(t *testing.T) {
+	// Saturday, 22:50 UTC
+	now := time.Date(2024, 1, 6, 22, 50, 0, 0, time.UTC)
+	schedule := fetchSchedule{
+		PreviousInterval: minFetchInterval,
+		NewPosts:         1,
+		SkipHours:        parseSkipHours([]string{"23", "24", "1"}),
+		SkipDays:         parseSkipDays([]string{"Sunday"}),
+	}
+
+	next, _ := schedule.nextFetch(now)
+
+	expected := time.Date(2024, 1, 8, 2, 0, 0, 0, time.UTC)
+	if !next.Equal(expected) {
+		t.Errorf("Expected next fetch at %v, got %v", expected, next)
+	}
+}
+
+func TestCacheLifetime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{"No caching headers", http.Header{}, 0},
+		{"Cache-Control max-age", http.Header{"Cache-Control": {"public, max-age=1800"}}, 30 * time.Minute},
+		{"Cache-Control no-cache", http.Header{"Cache-Control": {"no-cache"}, "Expires": {"Mon, 01 Jan 2024 14:00:00 GMT"}}, 0},
+		{"Expires header", http.Header{"Expires": {"Mon, 01 Jan 2024 14:00:00 GMT"}}, 2 * time.Hour},
+		{"Expires relative to Date", http.Header{"Expires": {"Mon, 01 Jan 2024 14:00:00 GMT"}, "Date": {"Mon, 01 Jan 2024 13:00:00 GMT"}}, time.Hour},
+		{"Expires in the past", http.Header{"Expires": {"Mon, 01 Jan 2024 10:00:00 GMT"}}, 0},
+		{"Invalid Expires", http.Header{"Expires": {"0"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lifetime := cacheLifetime(tt.header, now)
+			if lifetime != tt.expected {
+				t.Errorf("Expected lifetime %v, got %v", tt.expected, lifetime)
+			}
+		})
+	}
+}
+
+func TestFailureBackoff(t *testing.T) {
+	tests := []struct {
+		failures int32
+		expected time.Duration
+	}{
+		{0, failureBackoffBase},
+		{1, failureBackoffBase},
+		{2, 2 * failureBackoffBase},
+		{3, 4 * failureBackoffBase},
+		{6, 32 * failureBackoffBase},
+		{20, maxFetchInterval},
+		{1000, maxFetchInterval},
+	}
+
+	for _, tt := range tests {
+		backoff := failureBackoff(tt.failures)
+		if backoff != tt.expected {
+			t.Errorf("Expected backoff %v after %d failures, got %v", tt.expected, tt.failures, backoff)
+		}
+	}
+}
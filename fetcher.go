@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Fetcher retrieves and parses feeds. Feeds are always fetched through it, so it can be replaced in tests.
+type Fetcher interface {
+	// Fetch fetches and parses the feed found at url.
+	// When validators are present the request is made conditional, so unchanged feeds are not downloaded again.
+	// Failed fetches still return the status code and body size when the server answered.
+	Fetch(ctx context.Context, url string, validators cacheValidators) (feedResponse, error)
+}
+
+// cacheValidators are the response headers used to make conditional requests for a feed.
+type cacheValidators struct {
+	ETag         string
+	LastModified string
+}
+
+// feedResponse holds the outcome of fetching a feed.
+type feedResponse struct {
+	Feed        RSSFeed
+	NotModified bool // the server answered 304 Not Modified, so Feed is empty
+	Validators  cacheValidators
+	// CacheLifetime is how long the response may be cached, according to the HTTP caching headers
+	CacheLifetime time.Duration
+	// PermanentURL is set when the feed was reached only through permanent redirects (301 or 308)
+	PermanentURL string
+	StatusCode   int
+	BodySize     int64 // bytes read from the response body, before decompression
+}
+
+const (
+	defaultFetchTimeout = 10 * time.Second
+	defaultUserAgent    = "curator/1.0 (+https://github.com/deadpyxel/curator)"
+	defaultMaxRedirects = 10
+	// defaultMaxFeedBodySize is the largest feed document accepted, in bytes.
+	defaultMaxFeedBodySize     = 10 << 20
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// fetcherConfig holds the HTTP settings used to fetch feeds.
+type fetcherConfig struct {
+	Timeout   time.Duration
+	UserAgent string
+	// ProxyURL is used for every request when set, otherwise the proxy comes from the HTTP_PROXY and HTTPS_PROXY variables
+	ProxyURL     *url.URL
+	MaxRedirects int
+	// MaxBodySize limits the size of fetched feeds, so a misbehaving server cannot exhaust the memory
+	MaxBodySize int64
+	// Idle connections are kept open between fetches, so feeds hosted together reuse their connections
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+func defaultFetcherConfig() fetcherConfig {
+	return fetcherConfig{
+		Timeout:             defaultFetchTimeout,
+		UserAgent:           defaultUserAgent,
+		MaxRedirects:        defaultMaxRedirects,
+		MaxBodySize:         defaultMaxFeedBodySize,
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+	}
+}
+
+// fetcherConfigFromEnv reads the fetcher settings from the FEED_* environment variables.
+// Settings that are not defined keep their default values.
+func fetcherConfigFromEnv() (fetcherConfig, error) {
+	config := defaultFetcherConfig()
+
+	if timeoutStr := os.Getenv("FEED_TIMEOUT"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return fetcherConfig{}, fmt.Errorf("FEED_TIMEOUT is not a valid duration: %q", timeoutStr)
+		}
+		config.Timeout = timeout
+	}
+	if userAgent := strings.TrimSpace(os.Getenv("FEED_USER_AGENT")); userAgent != "" {
+		config.UserAgent = userAgent
+	}
+	if proxyStr := os.Getenv("FEED_PROXY_URL"); proxyStr != "" {
+		proxyURL, err := url.Parse(proxyStr)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fetcherConfig{}, fmt.Errorf("FEED_PROXY_URL is not a valid URL: %q", proxyStr)
+		}
+		config.ProxyURL = proxyURL
+	}
+	if redirectsStr := os.Getenv("FEED_MAX_REDIRECTS"); redirectsStr != "" {
+		redirects, err := strconv.Atoi(redirectsStr)
+		if err != nil || redirects < 0 {
+			return fetcherConfig{}, fmt.Errorf("FEED_MAX_REDIRECTS is not a valid number: %q", redirectsStr)
+		}
+		config.MaxRedirects = redirects
+	}
+	if bodySizeStr := os.Getenv("FEED_MAX_BODY_SIZE"); bodySizeStr != "" {
+		bodySize, err := strconv.ParseInt(bodySizeStr, 10, 64)
+		if err != nil || bodySize <= 0 {
+			return fetcherConfig{}, fmt.Errorf("FEED_MAX_BODY_SIZE is not a valid number of bytes: %q", bodySizeStr)
+		}
+		config.MaxBodySize = bodySize
+	}
+	if idleConnsStr := os.Getenv("FEED_MAX_IDLE_CONNS_PER_HOST"); idleConnsStr != "" {
+		idleConns, err := strconv.Atoi(idleConnsStr)
+		if err != nil || idleConns < 0 {
+			return fetcherConfig{}, fmt.Errorf("FEED_MAX_IDLE_CONNS_PER_HOST is not a valid number: %q", idleConnsStr)
+		}
+		config.MaxIdleConnsPerHost = idleConns
+	}
+
+	return config, nil
+}
+
+// httpFetcher fetches feeds over HTTP. A single client is shared by all fetches, so connections are pooled.
+type httpFetcher struct {
+	client *http.Client
+	config fetcherConfig
+}
+
+// webClient makes the requests that are not feed fetches, like web pages, icons, robots.txt files and WebSub subscriptions.
+// It shares the client of the feed fetcher, so these requests use the same connections, proxy, timeout and User-Agent.
+type webClient struct {
+	client      *http.Client
+	userAgent   string
+	maxBodySize int64
+	hosts       *hostLimiter // nil when requests are not limited per host
+}
+
+// webClient returns a webClient using the client and settings of the fetcher.
+func (f *httpFetcher) webClient() *webClient {
+	return &webClient{
+		client:      f.client,
+		userAgent:   f.config.UserAgent,
+		maxBodySize: f.config.MaxBodySize,
+	}
+}
+
+// withHostLimits returns a copy of the client waiting for hosts before each request,
+// so the requests the crawler makes around feeds are spaced like the feeds themselves.
+func (w *webClient) withHostLimits(hosts *hostLimiter) *webClient {
+	limited := *w
+	limited.hosts = hosts
+	return &limited
+}
+
+// do sends req with the configured User-Agent.
+func (w *webClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", w.userAgent)
+	if w.hosts != nil {
+		release, err := w.hosts.acquire(req.Context(), strings.ToLower(req.URL.Host), 0)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+	return w.client.Do(req)
+}
+
+// get makes a GET request for rawURL.
+func (w *webClient) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return w.do(req)
+}
+
+// webPageError is returned when a URL fetched as a feed points to a web page instead.
+// It holds the start of the page, so the feeds it links to can be looked up without downloading it again.
+type webPageError struct {
+	URL  *url.URL // URL of the page after redirects, against which its links are resolved
+	Page []byte
+}
+
+func (e *webPageError) Error() string {
+	return fmt.Sprintf("%s is a web page, not a feed", e.URL)
+}
+
+func newHTTPFetcher(config fetcherConfig) *httpFetcher {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if config.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(config.ProxyURL)
+	}
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+
+	fetcher := &httpFetcher{config: config}
+	fetcher.client = &http.Client{
+		Timeout:       config.Timeout,
+		Transport:     transport,
+		CheckRedirect: fetcher.checkRedirect,
+	}
+	return fetcher
+}
+
+var errFeedTooLarge = errors.New("feed too large")
+
+// limitedReader reads from reader, failing with errFeedTooLarge once more than limit bytes are read.
+// Unlike io.LimitReader, which reports a silent EOF, reaching the limit is an error, so truncated feeds are not parsed.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// A body of exactly limit bytes is fine, so only fail when there is something left to read
+		var probe [1]byte
+		n, err := l.reader.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: more than %d bytes", errFeedTooLarge, l.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from reader.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// redirectTracker records where a chain of permanent redirects leads, so the stored feed URL can be updated.
+type redirectTracker struct {
+	permanentURL string
+	temporary    bool
+}
+
+// redirectTrackerKey is the request context key holding the redirectTracker of a fetch.
+type redirectTrackerKey struct{}
+
+// record tracks the redirect leading to req.
+// Only the permanent redirects found before any temporary one in the chain are followed when updating the URL.
+func (t *redirectTracker) record(req *http.Request) {
+	if req.Response == nil {
+		return
+	}
+	switch req.Response.StatusCode {
+	case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		if !t.temporary {
+			t.permanentURL = req.URL.String()
+		}
+	default:
+		t.temporary = true
+	}
+}
+
+// checkRedirect is used as the http.Client CheckRedirect function.
+// The client is shared between fetches, so the redirects of each fetch are recorded in the tracker found in its context.
+func (f *httpFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.config.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", f.config.MaxRedirects)
+	}
+	if tracker, ok := req.Context().Value(redirectTrackerKey{}).(*redirectTracker); ok {
+		tracker.record(req)
+	}
+	return nil
+}
+
+// Fetch implements Fetcher.
+func (f *httpFetcher) Fetch(ctx context.Context, url string, validators cacheValidators) (feedResponse, error) {
+	redirects := &redirectTracker{}
+	ctx = context.WithValue(ctx, redirectTrackerKey{}, redirects)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return feedResponse{}, err
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	// Setting Accept-Encoding disables the transparent gzip support of the transport, so the body is decoded below
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return feedResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	lifetime := cacheLifetime(resp.Header, time.Now())
+	if resp.StatusCode == http.StatusNotModified {
+		return feedResponse{
+			NotModified:   true,
+			Validators:    validators,
+			CacheLifetime: lifetime,
+			PermanentURL:  redirects.permanentURL,
+			StatusCode:    resp.StatusCode,
+		}, nil
+	}
+	failed := feedResponse{StatusCode: resp.StatusCode}
+	if resp.StatusCode != http.StatusOK {
+		return failed, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// The declared length is checked first to avoid downloading part of a feed that is too large anyway
+	maxBodySize := f.config.MaxBodySize
+	if resp.ContentLength > maxBodySize {
+		return failed, fmt.Errorf("%w: %d bytes, limit is %d bytes", errFeedTooLarge, resp.ContentLength, maxBodySize)
+	}
+	counter := &countingReader{reader: resp.Body}
+	decoded, err := decodeContentEncoding(resp.Header.Get("Content-Encoding"), counter)
+	if err != nil {
+		failed.BodySize = counter.count
+		return failed, err
+	}
+	// The limit applies to the decoded body, so a small compressed response cannot expand without bounds
+	body := bufio.NewReaderSize(&limitedReader{reader: decoded, remaining: maxBodySize, limit: maxBodySize}, sniffSize)
+
+	// Only the body is sniffed, as some servers send their feeds as text/html
+	start, err := body.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		failed.BodySize = counter.count
+		return failed, err
+	}
+	if strings.HasPrefix(http.DetectContentType(start), "text/html") {
+		page, err := io.ReadAll(io.LimitReader(body, maxPageSize))
+		failed.BodySize = counter.count
+		if err != nil {
+			return failed, err
+		}
+		return failed, &webPageError{URL: resp.Request.URL, Page: page}
+	}
+
+	rssFeed, err := parseFeed(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		failed.BodySize = counter.count
+		return failed, err
+	}
+
+	return feedResponse{
+		Feed: rssFeed,
+		Validators: cacheValidators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		CacheLifetime: lifetime,
+		PermanentURL:  redirects.permanentURL,
+		StatusCode:    resp.StatusCode,
+		BodySize:      counter.count,
+	}, nil
+}
+
+// decodeContentEncoding returns a reader decompressing body according to the Content-Encoding header.
+func decodeContentEncoding(contentEncoding string, body io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	case "br":
+		return brotli.NewReader(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %q", contentEncoding)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// fakeFetcher is a Fetcher serving canned feeds, for tests that should not reach the network.
+type fakeFetcher struct {
+	feeds     map[string]RSSFeed
+	requested []string
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, url string, validators cacheValidators) (feedResponse, error) {
+	f.requested = append(f.requested, url)
+	feed, ok := f.feeds[url]
+	if !ok {
+		return feedResponse{}, errors.New("unexpected status code: 404")
+	}
+	return feedResponse{Feed: feed}, nil
+}
+
+func TestHTTPFetcherConditionalRequest(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssDocument))
+	}))
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	t.Run("First fetch returns feed and validators", func(t *testing.T) {
+		resp, err := fetcher.Fetch(context.Background(), server.URL, cacheValidators{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if resp.NotModified {
+			t.Error("Expected a full response")
+		}
+		if len(resp.Feed.Channel.Item) != 1 {
+			t.Errorf("Expected 1 item, got %d", len(resp.Feed.Channel.Item))
+		}
+		if resp.Validators.ETag != etag || resp.Validators.LastModified != lastModified {
+			t.Errorf("Expected validators to be returned, got %+v", resp.Validators)
+		}
+	})
+
+	t.Run("Conditional fetch returns not modified", func(t *testing.T) {
+		resp, err := fetcher.Fetch(context.Background(), server.URL, cacheValidators{ETag: etag, LastModified: lastModified})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if !resp.NotModified {
+			t.Error("Expected not modified response")
+		}
+		if resp.Validators.ETag != etag {
+			t.Errorf("Expected validators to be kept, got %+v", resp.Validators)
+		}
+	})
+}
+
+func TestHTTPFetcherPermanentRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/current", http.StatusPermanentRedirect)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/current", http.StatusFound)
+	})
+	mux.HandleFunc("/moved-then-temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/temporary", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/current", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(rssDocument))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"No redirect", "/current", ""},
+		{"Only permanent redirects", "/old", server.URL + "/current"},
+		{"Only temporary redirects", "/temporary", ""},
+		{"Permanent then temporary redirect", "/moved-then-temporary", server.URL + "/temporary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := fetcher.Fetch(context.Background(), server.URL+tt.path, cacheValidators{})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if resp.PermanentURL != tt.expected {
+				t.Errorf("Expected permanent URL %q, got %q", tt.expected, resp.PermanentURL)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherSizeLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		if r.URL.Path == "/chunked" {
+			// Flushing before the end of the body sends it without a Content-Length
+			w.Write([]byte(rssDocument[:10]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(rssDocument[10:]))
+			return
+		}
+		w.Write([]byte(rssDocument))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		limit       int64
+		expectedErr error
+	}{
+		{"Body within the limit", "/", int64(len(rssDocument)), nil},
+		{"Declared length over the limit", "/", int64(len(rssDocument)) - 1, errFeedTooLarge},
+		{"Chunked body within the limit", "/chunked", int64(len(rssDocument)), nil},
+		{"Chunked body over the limit", "/chunked", int64(len(rssDocument)) - 1, errFeedTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultFetcherConfig()
+			config.MaxBodySize = tt.limit
+			_, err := newHTTPFetcher(config).Fetch(context.Background(), server.URL+tt.path, cacheValidators{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherUserAgent(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		w.Write([]byte(rssDocument))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{"Default User-Agent", "", defaultUserAgent},
+		{"Custom User-Agent", "my-reader/2.0", "my-reader/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultFetcherConfig()
+			if tt.userAgent != "" {
+				config.UserAgent = tt.userAgent
+			}
+			if _, err := newHTTPFetcher(config).Fetch(context.Background(), server.URL, cacheValidators{}); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if userAgent != tt.expected {
+				t.Errorf("Expected User-Agent %q, got %q", tt.expected, userAgent)
+			}
+
+			// Requests made around feeds, like favicon lookups, share the fetcher settings
+			findFavicon(context.Background(), newHTTPFetcher(config).webClient(), server.URL)
+			if userAgent != tt.expected {
+				t.Errorf("Expected web client User-Agent %q, got %q", tt.expected, userAgent)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherWebPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Both are sent as text/html, but only the page is HTML
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Path == "/page" {
+			w.Write([]byte(`<!DOCTYPE html><html><head><link rel="alternate" type="application/rss+xml" href="/rss.xml"></head></html>`))
+			return
+		}
+		w.Write([]byte(rssDocument))
+	}))
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/page", cacheValidators{})
+	var page *webPageError
+	if !errors.As(err, &page) {
+		t.Fatalf("Expected web page error, got %v", err)
+	}
+	if page.URL.String() != server.URL+"/page" || !strings.Contains(string(page.Page), "application/rss+xml") {
+		t.Errorf("Expected the page to be returned with its URL, got %s: %q", page.URL, page.Page)
+	}
+
+	resp, err := fetcher.Fetch(context.Background(), server.URL+"/feed", cacheValidators{})
+	if err != nil {
+		t.Fatalf("Expected feed sent as text/html to be parsed, got %v", err)
+	}
+	if resp.Feed.Channel.Title != "RSS Channel" {
+		t.Errorf("Expected channel title %q, got %q", "RSS Channel", resp.Feed.Channel.Title)
+	}
+}
+
+func TestHTTPFetcherContentEncoding(t *testing.T) {
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		writer := newWriter(&buf)
+		writer.Write([]byte(rssDocument))
+		writer.Close()
+		return buf.Bytes()
+	}
+	bodies := map[string][]byte{
+		"gzip":    compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+		"deflate": compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+		"br":      compress(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }),
+		"":        []byte(rssDocument),
+	}
+
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		encoding := r.URL.Query().Get("encoding")
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(bodies[encoding])
+	}))
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	for encoding := range bodies {
+		t.Run("Encoding "+encoding, func(t *testing.T) {
+			resp, err := fetcher.Fetch(context.Background(), server.URL+"/?encoding="+encoding, cacheValidators{})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(resp.Feed.Channel.Item) != 1 {
+				t.Errorf("Expected 1 item, got %d", len(resp.Feed.Channel.Item))
+			}
+			if acceptEncoding != "gzip, deflate, br" {
+				t.Errorf("Expected compressed responses to be accepted, got %q", acceptEncoding)
+			}
+		})
+	}
+
+	t.Run("Unsupported encoding", func(t *testing.T) {
+		_, err := fetcher.Fetch(context.Background(), server.URL+"/?encoding=zstd", cacheValidators{})
+		if err == nil || !strings.Contains(err.Error(), "unsupported content encoding") {
+			t.Errorf("Expected unsupported content encoding error, got %v", err)
+		}
+	})
+}
+
+func TestHTTPFetcherMaxRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/2", http.StatusFound)
+	})
+	mux.HandleFunc("/2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed", http.StatusFound)
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(rssDocument))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		maxRedirects int
+		expectErr    bool
+	}{
+		{"Redirects within the limit", 2, false},
+		{"Too many redirects", 1, true},
+		{"Redirects disabled", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultFetcherConfig()
+			config.MaxRedirects = tt.maxRedirects
+			_, err := newHTTPFetcher(config).Fetch(context.Background(), server.URL+"/1", cacheValidators{})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error %t, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherProxy(t *testing.T) {
+	var proxiedURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests sent through a proxy carry the absolute URL of the target
+		proxiedURL = r.URL.String()
+		w.Write([]byte(rssDocument))
+	}))
+	defer proxy.Close()
+
+	config := defaultFetcherConfig()
+	config.ProxyURL, _ = url.Parse(proxy.URL)
+	resp, err := newHTTPFetcher(config).Fetch(context.Background(), "http://feeds.example.com/rss.xml", cacheValidators{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if proxiedURL != "http://feeds.example.com/rss.xml" {
+		t.Errorf("Expected the request to go through the proxy, got %q", proxiedURL)
+	}
+	if len(resp.Feed.Channel.Item) != 1 {
+		t.Errorf("Expected 1 item, got %d", len(resp.Feed.Channel.Item))
+	}
+}
+
+func TestFetcherConfigFromEnv(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := fetcherConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if config != defaultFetcherConfig() {
+			t.Errorf("Expected default config, got %+v", config)
+		}
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		t.Setenv("FEED_TIMEOUT", "30s")
+		t.Setenv("FEED_USER_AGENT", "my-reader/2.0")
+		t.Setenv("FEED_PROXY_URL", "http://proxy.example.com:3128")
+		t.Setenv("FEED_MAX_REDIRECTS", "3")
+		t.Setenv("FEED_MAX_BODY_SIZE", "1024")
+		config, err := fetcherConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if config.Timeout.String() != "30s" || config.UserAgent != "my-reader/2.0" || config.ProxyURL.Host != "proxy.example.com:3128" ||
+			config.MaxRedirects != 3 || config.MaxBodySize != 1024 {
+			t.Errorf("Expected environment settings to be applied, got %+v", config)
+		}
+	})
+
+	invalid := map[string]string{
+		"FEED_TIMEOUT":       "soon",
+		"FEED_PROXY_URL":     "proxy",
+		"FEED_MAX_REDIRECTS": "-1",
+		"FEED_MAX_BODY_SIZE": "0",
+	}
+	for name, value := range invalid {
+		t.Run("Invalid "+name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := fetcherConfigFromEnv(); err == nil {
+				t.Errorf("Expected an error for %s=%q", name, value)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherResponseDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(rssDocument))
+	}))
+	defer server.Close()
+	fetcher := newHTTPFetcher(defaultFetcherConfig())
+
+	tests := []struct {
+		name               string
+		url                string
+		expectErr          bool
+		expectedStatusCode int
+		expectedBodySize   int64
+	}{
+		{"Successful fetch", server.URL + "/", false, http.StatusOK, int64(len(rssDocument))},
+		{"Unexpected status", server.URL + "/missing", true, http.StatusNotFound, 0},
+		{"Unreachable server", "http://127.0.0.1:1/", true, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := fetcher.Fetch(context.Background(), tt.url, cacheValidators{})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error %t, got %v", tt.expectErr, err)
+			}
+			if resp.StatusCode != tt.expectedStatusCode || resp.BodySize != tt.expectedBodySize {
+				t.Errorf("Expected status %d and %d bytes, got status %d and %d bytes", tt.expectedStatusCode, tt.expectedBodySize, resp.StatusCode, resp.BodySize)
+			}
+		})
+	}
+}
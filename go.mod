@@ -3,7 +3,11 @@ module github.com/deadpyxel/curator
 go 1.22.4
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	golang.org/x/net v0.21.0
 )
+
+require golang.org/x/text v0.14.0 // indirect
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/deadpyxel/curator/internal/database"
@@ -71,12 +76,63 @@ func (apiCfg *apiConfig) handlerCreateFeed(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	parsedURL, err := url.Parse(params.Url)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		respondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Invalid feed URL: %q is not an absolute http(s) URL", params.Url))
+		return
+	}
+
+	// Users often provide the website address instead of the feed, so look for the feeds advertised by the page.
+	// Feeds are fetched through the feed parser, so only valid feeds end up in the crawler queue
+	feedURL := params.Url
+	probe, isPage, candidates, err := discoverFeeds(r.Context(), apiCfg.Fetcher, params.Url)
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Could not fetch a valid feed from %s: %v", params.Url, err))
+		return
+	}
+	if isPage {
+		switch len(candidates) {
+		case 0:
+			respondWithError(w, http.StatusUnprocessableEntity, "No feeds found on the provided page")
+			return
+		case 1:
+			feedURL = candidates[0].URL
+		default:
+			// Let the client choose which of the advertised feeds to follow
+			respondWithJSON(w, http.StatusMultipleChoices, struct {
+				Candidates []feedCandidate `json:"candidates"`
+			}{
+				Candidates: candidates,
+			})
+			return
+		}
+
+		// Candidates found at common feed paths were already fetched, while advertised ones still need to be validated
+		if candidates[0].probe != nil {
+			probe = *candidates[0].probe
+		} else {
+			probe, err = apiCfg.Fetcher.Fetch(r.Context(), feedURL, cacheValidators{})
+			if err != nil {
+				respondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Could not fetch a valid feed from %s: %v", feedURL, err))
+				return
+			}
+		}
+	}
+
+	feedName := strings.TrimSpace(params.Name)
+	if feedName == "" {
+		feedName = probe.Feed.Channel.Title
+	}
+	if feedName == "" {
+		feedName = feedURL
+	}
+
 	feed, err := apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
 		ID:        uuid.New(),
-		Name:      params.Name,
+		Name:      feedName,
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
-		Url:       params.Url,
+		Url:       feedURL,
 		UserID:    dbUser.ID,
 	})
 	if err != nil {
@@ -123,6 +179,156 @@ func (apiCfg *apiConfig) handlerGetFeeds(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusOK, dbFeedsToFeeds(feeds))
 }
 
+// handlerEnableFeed re-enables a feed disabled after too many failed fetches, and probes it right away.
+// Only the user who created the feed can enable it.
+func (apiCfg *apiConfig) handlerEnableFeed(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing feed ID: %v", err))
+		return
+	}
+
+	feed, err := apiCfg.DB.GetFeedByID(r.Context(), feedID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed: %v", err))
+		return
+	}
+	if err != nil || feed.UserID != dbUser.ID {
+		respondWithError(w, http.StatusNotFound, "Specified feed not found or not owned by user")
+		return
+	}
+	if !feed.DisabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Specified feed is not disabled")
+		return
+	}
+
+	feed, err = apiCfg.DB.EnableFeed(r.Context(), database.EnableFeedParams{
+		ID:     feedID,
+		UserID: dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to enable feed: %v", err))
+		return
+	}
+
+	// The probe result is recorded on the feed itself, so a failure is reported through the feed status
+	summary, probeErr := apiCfg.Crawler.scrapeFeed(r.Context(), feed)
+	if probeErr != nil {
+		logger.Warn("Probe fetch failed for re-enabled feed", "feedID", feed.ID, "error", probeErr)
+	}
+	// A feed redirected to the URL of another feed is merged into it, so the surviving feed is returned
+	if summary.MergedInto != uuid.Nil {
+		feed.ID = summary.MergedInto
+	}
+
+	feed, err = apiCfg.DB.GetFeedByID(r.Context(), feed.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed: %v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dbFeedToFeed(feed))
+}
+
+// handlerRefreshFeed fetches a feed right away, instead of waiting for its turn in the crawler, and reports what it found.
+func (apiCfg *apiConfig) handlerRefreshFeed(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing feed ID: %v", err))
+		return
+	}
+
+	// Only followers get to refresh a feed, everyone else is told it does not exist
+	_, err = apiCfg.DB.GetFeedFollowForFeed(r.Context(), database.GetFeedFollowForFeedParams{
+		UserID: dbUser.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Specified feed not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed follow: %v", err))
+		return
+	}
+
+	feed, err := apiCfg.DB.GetFeedByID(r.Context(), feedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Specified feed not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed: %v", err))
+		return
+	}
+	if feed.DisabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Specified feed is disabled, enable it before refreshing")
+		return
+	}
+
+	// The feed is claimed first, so a refresh refused because of a fetch in progress does not count towards the limit
+	if !apiCfg.Crawler.claim(feed.ID) {
+		respondWithError(w, http.StatusConflict, "Specified feed is already being fetched")
+		return
+	}
+	defer apiCfg.Crawler.release(feed.ID)
+
+	if retryAfter, ok := apiCfg.RefreshLimiter.allow(dbUser.ID, time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many refresh requests, try again later")
+		return
+	}
+
+	// Fetch failures are recorded on the feed as usual, and reported to the client through the summary
+	summary, err := apiCfg.Crawler.fetchFeed(r.Context(), feed)
+	respondWithJSON(w, http.StatusOK, fetchSummaryToFeedRefresh(summary, err))
+}
+
+// handlerGetFeedFetches lists the most recent fetch attempts of a feed, so its owner can find out why posts are missing.
+func (apiCfg *apiConfig) handlerGetFeedFetches(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing feed ID: %v", err))
+		return
+	}
+
+	queryLimit := 20 // set default query limit to 20 fetches
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Unable to parse limit query param")
+			return
+		}
+		// Only the last maxFeedFetches attempts are kept, so larger limits would not return more
+		if parsedLimit <= 0 || parsedLimit > maxFeedFetches {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Limit must be between 1 and %d", maxFeedFetches))
+			return
+		}
+		queryLimit = parsedLimit
+	}
+
+	feed, err := apiCfg.DB.GetFeedByID(r.Context(), feedID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed: %v", err))
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) || feed.UserID != dbUser.ID {
+		respondWithError(w, http.StatusNotFound, "Specified feed not found or not owned by user")
+		return
+	}
+
+	fetches, err := apiCfg.DB.GetFeedFetches(r.Context(), database.GetFeedFetchesParams{
+		FeedID: feedID,
+		Limit:  int32(queryLimit),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve feed fetches: %v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dbFeedFetchesToFeedFetches(fetches))
+}
+
 func (apiCfg *apiConfig) handlerCreateFeedFollow(w http.ResponseWriter, r *http.Request, dbUser database.User) {
 	type parameters struct {
 		FeedID uuid.UUID `json:"feed_id"`
@@ -202,9 +408,15 @@ func (apiCfg *apiConfig) handlerGetPostsByUser(w http.ResponseWriter, r *http.Re
 		queryLimit = parsedLimit
 	}
 
+	// Posts can be filtered by author and category, empty values mean no filter
+	author := strings.TrimSpace(queryParams.Get("author"))
+	category := strings.TrimSpace(queryParams.Get("category"))
+
 	posts, err := apiCfg.DB.GetPostsByUser(r.Context(), database.GetPostsByUserParams{
-		UserID: dbUser.ID,
-		Limit:  int32(queryLimit),
+		UserID:   dbUser.ID,
+		Author:   sql.NullString{String: author, Valid: author != ""},
+		Category: sql.NullString{String: category, Valid: category != ""},
+		MaxPosts: int32(queryLimit),
 	})
 
 	if err != nil {
@@ -216,5 +428,177 @@ func (apiCfg *apiConfig) handlerGetPostsByUser(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, dbPostsToPosts(posts))
+	metadata, err := apiCfg.loadPostMetadata(r.Context(), posts)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve post metadata: %v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dbPostsToPosts(posts, metadata))
+}
+
+// loadPostMetadata fetches the enclosures, authors and categories of the posts.
+func (apiCfg *apiConfig) loadPostMetadata(ctx context.Context, posts []database.Post) (postMetadata, error) {
+	postIDs := make([]uuid.UUID, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	var metadata postMetadata
+	var err error
+	metadata.Enclosures, err = apiCfg.DB.GetEnclosuresByPosts(ctx, postIDs)
+	if err != nil {
+		return postMetadata{}, err
+	}
+	metadata.Authors, err = apiCfg.DB.GetAuthorsByPosts(ctx, postIDs)
+	if err != nil {
+		return postMetadata{}, err
+	}
+	metadata.Categories, err = apiCfg.DB.GetCategoriesByPosts(ctx, postIDs)
+	if err != nil {
+		return postMetadata{}, err
+	}
+	return metadata, nil
+}
+
+// handlerGetPostRevisions lists the earlier versions of a post, kept whenever its feed edited it.
+// Posts from feeds the user does not follow, or that do not exist, return an empty list rather than 404.
+func (apiCfg *apiConfig) handlerGetPostRevisions(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	postID, err := uuid.Parse(r.PathValue("postID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing post ID: %v", err))
+		return
+	}
+
+	// Only revisions of posts from feeds followed by the user are returned
+	revisions, err := apiCfg.DB.GetPostRevisions(r.Context(), database.GetPostRevisionsParams{
+		PostID: postID,
+		UserID: dbUser.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve post revisions: %v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, dbPostRevisionsToPostRevisions(revisions))
+}
+
+// handlerWebSubVerify answers the requests hubs send to verify a subscription, or to report that it was denied.
+func (apiCfg *apiConfig) handlerWebSubVerify(w http.ResponseWriter, r *http.Request) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing feed ID: %v", err))
+		return
+	}
+
+	subscription, err := apiCfg.DB.GetWebSubSubscription(r.Context(), feedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Specified subscription not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve subscription: %v", err))
+		return
+	}
+
+	query := r.URL.Query()
+	switch query.Get("hub.mode") {
+	case "subscribe":
+		challenge := query.Get("hub.challenge")
+		if challenge == "" {
+			respondWithError(w, http.StatusBadRequest, "Missing hub.challenge")
+			return
+		}
+		// Answering the challenge confirms the subscription, and the callback is public,
+		// so it is refused unless the crawler is waiting for the hub to verify this very topic
+		if query.Get("hub.topic") != subscription.TopicUrl {
+			respondWithError(w, http.StatusNotFound, "Unknown topic")
+			return
+		}
+		if !websubRequestPending(subscription, time.Now().UTC()) {
+			respondWithError(w, http.StatusNotFound, "No subscription request pending")
+			return
+		}
+		// The lease decides when the feed is polled again, so it is never longer than the one requested
+		leaseSeconds, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+		if err != nil || leaseSeconds <= 0 || leaseSeconds > websubLeaseSeconds {
+			leaseSeconds = websubLeaseSeconds
+		}
+		err = apiCfg.DB.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+			FeedID:         feedID,
+			LeaseExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(time.Duration(leaseSeconds) * time.Second), Valid: true},
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not activate subscription: %v", err))
+			return
+		}
+		logger.Info("WebSub subscription verified", "feedID", feedID, "leaseSeconds", leaseSeconds)
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(challenge))
+	case "denied":
+		// The callback is public, so denials are only accepted for the topic that was requested, like confirmations
+		if query.Get("hub.topic") != subscription.TopicUrl {
+			respondWithError(w, http.StatusNotFound, "Unknown topic")
+			return
+		}
+		err = apiCfg.DB.DenyWebSubSubscription(r.Context(), feedID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not record subscription denial: %v", err))
+			return
+		}
+		logger.Warn("WebSub subscription denied by hub", "feedID", feedID, "reason", query.Get("hub.reason"))
+		w.WriteHeader(http.StatusOK)
+	default:
+		// Subscriptions are never cancelled from here, so unsubscribe requests are not confirmed either
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Unsupported hub.mode: %q", query.Get("hub.mode")))
+	}
+}
+
+// handlerWebSubPush receives the content hubs push for subscribed feeds and stores its items as posts.
+func (apiCfg *apiConfig) handlerWebSubPush(w http.ResponseWriter, r *http.Request) {
+	feedID, err := uuid.Parse(r.PathValue("feedID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing feed ID: %v", err))
+		return
+	}
+
+	// Hubs stop pushing content to callbacks answering 410 Gone
+	if apiCfg.Crawler.websub == nil {
+		respondWithError(w, http.StatusGone, "WebSub is disabled")
+		return
+	}
+	subscription, err := apiCfg.DB.GetWebSubSubscription(r.Context(), feedID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusGone, "Specified subscription not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve subscription: %v", err))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, apiCfg.Crawler.websub.web.maxBodySize))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Could not read pushed content: %v", err))
+		return
+	}
+	// Content with a wrong signature must still be acknowledged, so it is dropped without telling the sender
+	if err := verifySignature(r.Header.Get("X-Hub-Signature"), subscription.Secret, body); err != nil {
+		logger.Warn("Dropping pushed content with an invalid signature", "feedID", feedID, "error", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	feed, err := apiCfg.DB.GetFeedByID(r.Context(), feedID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not retrieve feed: %v", err))
+		return
+	}
+	if err := apiCfg.Crawler.ingestPushedFeed(feed, r.Header.Get("Content-Type"), body); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Could not parse pushed content: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -3,7 +3,11 @@ package main
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/deadpyxel/curator/internal/database"
+	"github.com/google/uuid"
 )
 
 func TestReadinessEndpoint(t *testing.T) {
@@ -28,3 +32,77 @@ func TestReadinessEndpoint(t *testing.T) {
 			rr.Body.String(), expected)
 	}
 }
+
+func TestCreateFeedValidation(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.xml" {
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(`<rss><channel><item>`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<html><body>Not found</body></html>`))
+	}))
+	defer source.Close()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Relative URL", `{"name": "Feed", "url": "/feed.xml"}`},
+		{"Unsupported scheme", `{"name": "Feed", "url": "ftp://example.com/feed.xml"}`},
+		{"Unreachable URL", `{"name": "Feed", "url": "http://127.0.0.1:1/feed.xml"}`},
+		{"Page without feeds", `{"name": "Feed", "url": "` + source.URL + `/"}`},
+		{"Invalid feed document", `{"name": "Feed", "url": "` + source.URL + `/broken.xml"}`},
+	}
+
+	// Validation happens before any database access, so no database is needed
+	apiCfg := apiConfig{Fetcher: newHTTPFetcher(defaultFetcherConfig())}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/v1/feeds", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			apiCfg.handlerCreateFeed(rr, req, database.User{ID: uuid.New()})
+
+			if status := rr.Code; status != http.StatusUnprocessableEntity {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
+			}
+		})
+	}
+}
+
+func TestGetFeedFetchesLimitValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit string
+	}{
+		{"Not a number", "ten"},
+		{"Zero", "0"},
+		{"Negative", "-1"},
+		{"Past the kept history", "101"},
+	}
+
+	// The limit is checked before any database access, so no database is needed
+	apiCfg := apiConfig{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feedID := uuid.New().String()
+			req, err := http.NewRequest("GET", "/v1/feeds/"+feedID+"/fetches?limit="+tt.limit, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("feedID", feedID)
+
+			rr := httptest.NewRecorder()
+			apiCfg.handlerGetFeedFetches(rr, req, database.User{ID: uuid.New()})
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// excerptLength is the maximum number of characters in the plain text excerpt of a post.
+const excerptLength = 300
+
+// allowedElements lists the elements kept in sanitised HTML, with the attributes allowed on each of them.
+// Elements not listed here are removed, but their content is kept.
+var allowedElements = map[string]map[string]bool{
+	"a":          {"href": true},
+	"abbr":       {},
+	"audio":      {"src": true, "controls": true},
+	"b":          {},
+	"blockquote": {"cite": true},
+	"br":         {},
+	"caption":    {},
+	"cite":       {},
+	"code":       {},
+	"dd":         {},
+	"del":        {},
+	"details":    {},
+	"div":        {},
+	"dl":         {},
+	"dt":         {},
+	"em":         {},
+	"figcaption": {},
+	"figure":     {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src": true, "alt": true, "width": true, "height": true},
+	"ins":        {},
+	"kbd":        {},
+	"li":         {},
+	"mark":       {},
+	"ol":         {"start": true},
+	"p":          {},
+	"picture":    {},
+	"pre":        {},
+	"q":          {"cite": true},
+	"s":          {},
+	"small":      {},
+	"source":     {"src": true, "type": true},
+	"span":       {},
+	"strong":     {},
+	"sub":        {},
+	"summary":    {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"colspan": true, "rowspan": true},
+	"tfoot":      {},
+	"th":         {"colspan": true, "rowspan": true},
+	"thead":      {},
+	"time":       {"datetime": true},
+	"tr":         {},
+	"u":          {},
+	"ul":         {},
+	"video":      {"src": true, "poster": true, "controls": true, "width": true, "height": true},
+}
+
+// globalAttributes are allowed on every kept element.
+var globalAttributes = map[string]bool{"title": true, "lang": true, "dir": true}
+
+// droppedElements are removed together with all their content.
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true, "object": true, "embed": true,
+	"applet": true, "form": true, "input": true, "button": true, "select": true, "textarea": true, "template": true,
+	"noscript": true, "svg": true, "math": true, "head": true, "title": true, "meta": true, "link": true, "base": true,
+}
+
+// urlAttributes hold URLs, which are resolved against the item link and restricted to safe schemes.
+var urlAttributes = map[string]bool{"href": true, "src": true, "cite": true, "poster": true}
+
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// sanitizeHTML makes a post body safe to serve to clients.
+// It removes dangerous elements and attributes, rewrites relative links and images against baseURL
+// and removes 1x1 tracking images. baseURL can be nil, in which case relative URLs are kept as they are.
+func sanitizeHTML(body string, baseURL *url.URL) string {
+	var sb strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	// droppedDepth counts the open dropped elements, whose content is being skipped
+	droppedDepth := 0
+	droppedName := ""
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() != io.EOF {
+				logger.Debug("Error tokenizing HTML", "error", tokenizer.Err())
+			}
+			return sb.String()
+		}
+		token := tokenizer.Token()
+
+		if droppedDepth > 0 {
+			if token.Data == droppedName {
+				switch tokenType {
+				case html.StartTagToken:
+					droppedDepth++
+				case html.EndTagToken:
+					droppedDepth--
+				}
+			}
+			continue
+		}
+
+		switch tokenType {
+		case html.TextToken:
+			sb.WriteString(token.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if droppedElements[token.Data] {
+				if tokenType == html.StartTagToken && !isVoidElement(token.Data) {
+					droppedDepth = 1
+					droppedName = token.Data
+				}
+				continue
+			}
+			allowedAttrs, ok := allowedElements[token.Data]
+			if !ok {
+				continue
+			}
+			token.Attr = sanitizeAttributes(token.Attr, allowedAttrs, baseURL)
+			if token.Data == "img" && (isTrackingPixel(token.Attr) || attributeValue(token.Attr, "src") == "") {
+				continue
+			}
+			if token.Data == "a" {
+				token.Attr = append(token.Attr, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+			}
+			sb.WriteString(token.String())
+		case html.EndTagToken:
+			if _, ok := allowedElements[token.Data]; ok {
+				sb.WriteString(token.String())
+			}
+		}
+	}
+}
+
+// sanitizeAttributes keeps only the allowed attributes, resolving URLs and removing the ones using unsafe schemes.
+func sanitizeAttributes(attrs []html.Attribute, allowed map[string]bool, baseURL *url.URL) []html.Attribute {
+	kept := []html.Attribute{}
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || (!allowed[key] && !globalAttributes[key]) {
+			continue
+		}
+		if urlAttributes[key] {
+			value, ok := sanitizeURL(attr.Val, baseURL)
+			if !ok {
+				continue
+			}
+			attr.Val = value
+		}
+		attr.Key = key
+		kept = append(kept, attr)
+	}
+	return kept
+}
+
+// sanitizeURL resolves a URL against baseURL and reports if it is safe to keep.
+func sanitizeURL(value string, baseURL *url.URL) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+	if baseURL != nil {
+		parsed = baseURL.ResolveReference(parsed)
+	}
+	if parsed.Scheme != "" && !allowedSchemes[strings.ToLower(parsed.Scheme)] {
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+// isTrackingPixel reports if the image attributes describe an invisible image, usually used to track readers.
+func isTrackingPixel(attrs []html.Attribute) bool {
+	isTiny := func(value string) bool {
+		value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+		return value == "0" || value == "1"
+	}
+	return isTiny(attributeValue(attrs, "width")) && isTiny(attributeValue(attrs, "height"))
+}
+
+func attributeValue(attrs []html.Attribute, key string) string {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isVoidElement(name string) bool {
+	switch name {
+	case "area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr":
+		return true
+	}
+	return false
+}
+
+// htmlExcerpt returns the plain text of an HTML fragment, truncated to at most maxLength characters on a word boundary.
+func htmlExcerpt(body string, maxLength int) string {
+	var sb strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	skipDepth := 0
+
+loop:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if droppedElements[string(name)] && !isVoidElement(string(name)) {
+				skipDepth++
+			}
+			// Block elements separate words, even without whitespace in the markup
+			sb.WriteString(" ")
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if droppedElements[string(name)] && skipDepth > 0 {
+				skipDepth--
+			}
+			sb.WriteString(" ")
+		case html.SelfClosingTagToken:
+			sb.WriteString(" ")
+		case html.TextToken:
+			if skipDepth == 0 {
+				sb.WriteString(html.UnescapeString(string(tokenizer.Text())))
+			}
+		}
+	}
+
+	text := strings.Join(strings.Fields(sb.String()), " ")
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxLength])
+	if lastSpace := strings.LastIndex(cut, " "); lastSpace > 0 {
+		cut = cut[:lastSpace]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com/blog/post-1")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Keeps safe markup",
+			input:    `<p>Hello <strong>world</strong></p>`,
+			expected: `<p>Hello <strong>world</strong></p>`,
+		},
+		{
+			name:     "Removes scripts with their content",
+			input:    `<p>Hi</p><script>alert("x")</script><style>p{}</style>`,
+			expected: `<p>Hi</p>`,
+		},
+		{
+			name:     "Removes nested dropped elements",
+			input:    `<object><object><p>a</p></object><p>b</p></object><p>c</p>`,
+			expected: `<p>c</p>`,
+		},
+		{
+			name:     "Unwraps unknown elements",
+			input:    `<font color="red">Text</font>`,
+			expected: `Text`,
+		},
+		{
+			name:     "Removes event handlers and styles",
+			input:    `<p onclick="steal()" style="color:red" class="x">Text</p>`,
+			expected: `<p>Text</p>`,
+		},
+		{
+			name:     "Removes javascript links",
+			input:    `<a href="javascript:alert(1)">Click</a>`,
+			expected: `<a rel="nofollow noopener noreferrer">Click</a>`,
+		},
+		{
+			name:     "Resolves relative links",
+			input:    `<a href="../about">About</a>`,
+			expected: `<a href="https://example.com/about" rel="nofollow noopener noreferrer">About</a>`,
+		},
+		{
+			name:     "Resolves relative images",
+			input:    `<img src="images/cat.png" alt="Cat">`,
+			expected: `<img src="https://example.com/blog/images/cat.png" alt="Cat">`,
+		},
+		{
+			name:     "Removes tracking pixels",
+			input:    `<p>Text</p><img src="https://tracker.example.com/p.gif" width="1" height="1px">`,
+			expected: `<p>Text</p>`,
+		},
+		{
+			name:     "Removes images without a safe source",
+			input:    `<img src="data:image/png;base64,AAAA">`,
+			expected: ``,
+		},
+		{
+			name:     "Escapes plain text",
+			input:    `Fish & chips`,
+			expected: `Fish &amp; chips`,
+		},
+		{
+			name:     "Empty input",
+			input:    ``,
+			expected: ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sanitizeHTML(tt.input, baseURL)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLWithoutBaseURL(t *testing.T) {
+	result := sanitizeHTML(`<a href="/about">About</a>`, nil)
+	expected := `<a href="/about" rel="nofollow noopener noreferrer">About</a>`
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestHTMLExcerpt(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		maxLength int
+		expected  string
+	}{
+		{
+			name:      "Strips markup",
+			input:     `<p>Hello <em>world</em></p><p>Second&nbsp;paragraph</p>`,
+			maxLength: 100,
+			expected:  "Hello world Second paragraph",
+		},
+		{
+			name:      "Ignores scripts",
+			input:     `<p>Text</p><script>var x = 1;</script>`,
+			maxLength: 100,
+			expected:  "Text",
+		},
+		{
+			name:      "Truncates on a word boundary",
+			input:     `<p>The quick brown fox jumps</p>`,
+			maxLength: 12,
+			expected:  "The quick…",
+		},
+		{
+			name:      "Empty input",
+			input:     ``,
+			maxLength: 100,
+			expected:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := htmlExcerpt(tt.input, tt.maxLength)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTMLExcerptLength(t *testing.T) {
+	result := htmlExcerpt(strings.Repeat("word ", 200), excerptLength)
+	if length := len([]rune(result)); length > excerptLength+1 {
+		t.Errorf("Expected at most %d characters, got %d", excerptLength+1, length)
+	}
+}
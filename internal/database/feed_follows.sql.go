@@ -63,6 +63,28 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const getFeedFollowForFeed = `-- name: GetFeedFollowForFeed :one
+SELECT id, created_at, updated_at, user_id, feed_id FROM feed_follows WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowForFeedParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollowForFeed(ctx context.Context, arg GetFeedFollowForFeedParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollowForFeed, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+	)
+	return i, err
+}
+
 const getFeedFollowForUser = `-- name: GetFeedFollowForUser :many
 SELECT id, created_at, updated_at, user_id, feed_id FROM feed_follows WHERE user_id = $1
 `
@@ -95,3 +117,22 @@ func (q *Queries) GetFeedFollowForUser(ctx context.Context, userID uuid.UUID) ([
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+  SET
+    feed_id = $1,
+    updated_at = NOW()
+  WHERE feed_id = $2
+    AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = $1)
+`
+
+type MoveFeedFollowsParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
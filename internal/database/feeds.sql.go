@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,7 +20,7 @@ INSERT INTO feeds (
 VALUES
   (
     $1, $2, $3, $4, $5, $6
-  ) RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+  ) RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at
 `
 
 type CreateFeedParams struct {
@@ -49,12 +50,164 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.FaviconCheckedAt,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+UPDATE feeds
+  SET
+    disabled_at = $2,
+    updated_at = NOW()
+  WHERE id = $1
+`
+
+type DisableFeedParams struct {
+	ID         uuid.UUID
+	DisabledAt sql.NullTime
+}
+
+func (q *Queries) DisableFeed(ctx context.Context, arg DisableFeedParams) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, arg.ID, arg.DisabledAt)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+UPDATE feeds
+  SET
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+  WHERE id = $1 AND user_id = $2
+  RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at
+`
+
+type EnableFeedParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) EnableFeed(ctx context.Context, arg EnableFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.FaviconCheckedAt,
+	)
+	return i, err
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.FaviconCheckedAt,
+	)
+	return i, err
+}
+
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at FROM feeds WHERE url = $1
+`
+
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.FaviconCheckedAt,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -74,6 +227,20 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.FaviconCheckedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -89,13 +256,20 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-  ORDER BY last_fetched_at ASC NULLS FIRST
-  LIMIT $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at FROM feeds
+  WHERE disabled_at IS NULL
+    AND (next_fetch_at IS NULL OR next_fetch_at <= $1::timestamp)
+  ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+  LIMIT $2
 `
 
-func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
+type GetNextFeedsToFetchParams struct {
+	Now      time.Time
+	MaxFeeds int32
+}
+
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.Now, arg.MaxFeeds)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +285,20 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.NextFetchAt,
+			&i.FetchIntervalSeconds,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.FaviconCheckedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -131,7 +319,7 @@ UPDATE feeds
     last_fetched_at = NOW(),
     updated_at = NOW()
   WHERE id = $1
-  RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+  RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, next_fetch_at, fetch_interval_seconds, consecutive_failures, last_error, last_success_at, disabled_at, title, site_url, description, language, image_url, favicon_checked_at
 `
 
 func (q *Queries) MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
@@ -145,6 +333,157 @@ func (q *Queries) MarkFeedAsFetched(ctx context.Context, id uuid.UUID) (Feed, er
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.FaviconCheckedAt,
 	)
 	return i, err
 }
+
+const markFeedFetchFailed = `-- name: MarkFeedFetchFailed :exec
+UPDATE feeds
+  SET
+    consecutive_failures = $2,
+    last_error = $3,
+    next_fetch_at = $4
+  WHERE id = $1
+`
+
+type MarkFeedFetchFailedParams struct {
+	ID                  uuid.UUID
+	ConsecutiveFailures int32
+	LastError           sql.NullString
+	NextFetchAt         sql.NullTime
+}
+
+func (q *Queries) MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchFailed,
+		arg.ID,
+		arg.ConsecutiveFailures,
+		arg.LastError,
+		arg.NextFetchAt,
+	)
+	return err
+}
+
+const markFeedFetchSucceeded = `-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds
+  SET
+    consecutive_failures = 0,
+    last_error = NULL,
+    last_success_at = $2
+  WHERE id = $1
+`
+
+type MarkFeedFetchSucceededParams struct {
+	ID            uuid.UUID
+	LastSuccessAt sql.NullTime
+}
+
+func (q *Queries) MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchSucceeded, arg.ID, arg.LastSuccessAt)
+	return err
+}
+
+const scheduleNextFetch = `-- name: ScheduleNextFetch :exec
+UPDATE feeds
+  SET
+    next_fetch_at = $2,
+    fetch_interval_seconds = $3
+  WHERE id = $1
+`
+
+type ScheduleNextFetchParams struct {
+	ID                   uuid.UUID
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds int32
+}
+
+func (q *Queries) ScheduleNextFetch(ctx context.Context, arg ScheduleNextFetchParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleNextFetch, arg.ID, arg.NextFetchAt, arg.FetchIntervalSeconds)
+	return err
+}
+
+const updateFeedCacheValidators = `-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+  SET
+    etag = $2,
+    last_modified = $3
+  WHERE id = $1
+`
+
+type UpdateFeedCacheValidatorsParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedCacheValidators(ctx context.Context, arg UpdateFeedCacheValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedCacheValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :exec
+UPDATE feeds
+  SET
+    title = $1,
+    site_url = $2,
+    description = $3,
+    language = $4,
+    image_url = COALESCE($5::text, image_url),
+    favicon_checked_at = COALESCE($6::timestamp, favicon_checked_at)
+  WHERE id = $7
+`
+
+type UpdateFeedMetadataParams struct {
+	Title            sql.NullString
+	SiteUrl          sql.NullString
+	Description      sql.NullString
+	Language         sql.NullString
+	ImageUrl         sql.NullString
+	FaviconCheckedAt sql.NullTime
+	ID               uuid.UUID
+}
+
+// A missing image keeps the previously resolved one, and so does a missing favicon lookup time.
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedMetadata,
+		arg.Title,
+		arg.SiteUrl,
+		arg.Description,
+		arg.Language,
+		arg.ImageUrl,
+		arg.FaviconCheckedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+  SET
+    url = $2,
+    updated_at = NOW()
+  WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url)
+	return err
+}
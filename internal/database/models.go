@@ -11,14 +11,64 @@ import (
 	"github.com/google/uuid"
 )
 
+type Author struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Name      string
+}
+
+type Category struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Name      string
+}
+
+type Enclosure struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          uuid.UUID
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	LastFetchedAt        sql.NullTime
+	Etag                 sql.NullString
+	LastModified         sql.NullString
+	NextFetchAt          sql.NullTime
+	FetchIntervalSeconds int32
+	ConsecutiveFailures  int32
+	LastError            sql.NullString
+	LastSuccessAt        sql.NullTime
+	DisabledAt           sql.NullTime
+	Title                sql.NullString
+	SiteUrl              sql.NullString
+	Description          sql.NullString
+	Language             sql.NullString
+	ImageUrl             sql.NullString
+	FaviconCheckedAt     sql.NullTime
+}
+
+type FeedFetch struct {
+	ID           uuid.UUID
+	FeedID       uuid.UUID
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	StatusCode   sql.NullInt32
+	BodyBytes    int64
+	ItemsSeen    int32
+	PostsCreated int32
+	PostsUpdated int32
+	Error        sql.NullString
 }
 
 type FeedFollow struct {
@@ -30,14 +80,39 @@ type FeedFollow struct {
 }
 
 type Post struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Title                string
+	Url                  string
+	Description          sql.NullString
+	PublishedAt          time.Time
+	FeedID               uuid.UUID
+	PublishedAtEstimated bool
+	Content              sql.NullString
+	Excerpt              sql.NullString
+	Guid                 string
+	ContentHash          sql.NullString
+}
+
+type PostAuthor struct {
+	PostID   uuid.UUID
+	AuthorID uuid.UUID
+}
+
+type PostCategory struct {
+	PostID     uuid.UUID
+	CategoryID uuid.UUID
+}
+
+type PostRevision struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	PostID      uuid.UUID
 	Title       string
 	Url         string
 	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	Content     sql.NullString
 }
 
 type User struct {
@@ -47,3 +122,15 @@ type User struct {
 	Name      string
 	ApiKey    string
 }
+
+type WebsubSubscription struct {
+	FeedID         uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	HubUrl         string
+	TopicUrl       string
+	Secret         string
+	LeaseExpiresAt sql.NullTime
+	DeniedAt       sql.NullTime
+	RequestedAt    sql.NullTime
+}
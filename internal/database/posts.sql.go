@@ -15,22 +15,28 @@ import (
 
 const createPost = `-- name: CreatePost :one
 INSERT INTO posts (
-  id, created_at, updated_at, title, url, description, published_at, feed_id
+  id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_estimated, content, excerpt, guid,
+  content_hash
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_estimated, content, excerpt, guid, content_hash
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Title                string
+	Url                  string
+	Description          sql.NullString
+	PublishedAt          time.Time
+	FeedID               uuid.UUID
+	PublishedAtEstimated bool
+	Content              sql.NullString
+	Excerpt              sql.NullString
+	Guid                 string
+	ContentHash          sql.NullString
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -43,6 +49,11 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.PublishedAtEstimated,
+		arg.Content,
+		arg.Excerpt,
+		arg.Guid,
+		arg.ContentHash,
 	)
 	var i Post
 	err := row.Scan(
@@ -54,16 +65,50 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.PublishedAtEstimated,
+		&i.Content,
+		&i.Excerpt,
+		&i.Guid,
+		&i.ContentHash,
 	)
 	return i, err
 }
 
-const findPostByURL = `-- name: FindPostByURL :one
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts WHERE url = $1
+const deleteDuplicatePosts = `-- name: DeleteDuplicatePosts :exec
+DELETE FROM posts
+WHERE feed_id = $1
+  AND guid IN (SELECT guid FROM posts AS target WHERE target.feed_id = $2)
+`
+
+type DeleteDuplicatePostsParams struct {
+	SourceFeedID uuid.UUID
+	TargetFeedID uuid.UUID
+}
+
+// Deletes the posts of the source feed that are already present in the target feed.
+func (q *Queries) DeleteDuplicatePosts(ctx context.Context, arg DeleteDuplicatePostsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteDuplicatePosts, arg.SourceFeedID, arg.TargetFeedID)
+	return err
+}
+
+const findFeedPost = `-- name: FindFeedPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_estimated, content, excerpt, guid, content_hash FROM posts
+WHERE feed_id = $1
+  AND (guid = $2 OR (url = $3 AND guid = url))
+ORDER BY guid = $2 DESC
+LIMIT 1
 `
 
-func (q *Queries) FindPostByURL(ctx context.Context, url string) (Post, error) {
-	row := q.db.QueryRowContext(ctx, findPostByURL, url)
+type FindFeedPostParams struct {
+	FeedID uuid.UUID
+	Guid   string
+	Url    sql.NullString
+}
+
+// Posts are matched on their guid, falling back to the link for posts stored before the feed provided guids.
+// Those posts have their link as guid, so posts of feeds using the same link for every item are not matched by link.
+func (q *Queries) FindFeedPost(ctx context.Context, arg FindFeedPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, findFeedPost, arg.FeedID, arg.Guid, arg.Url)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -74,25 +119,48 @@ func (q *Queries) FindPostByURL(ctx context.Context, url string) (Post, error) {
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.PublishedAtEstimated,
+		&i.Content,
+		&i.Excerpt,
+		&i.Guid,
+		&i.ContentHash,
 	)
 	return i, err
 }
 
 const getPostsByUser = `-- name: GetPostsByUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.published_at_estimated, posts.content, posts.excerpt, posts.guid, posts.content_hash FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
 WHERE feed_follows.user_id = $1
+  AND ($2::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_authors
+    INNER JOIN authors ON post_authors.author_id = authors.id
+    WHERE post_authors.post_id = posts.id AND lower(authors.name) = lower($2)
+  ))
+  AND ($3::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    INNER JOIN categories ON post_categories.category_id = categories.id
+    WHERE post_categories.post_id = posts.id AND lower(categories.name) = lower($3)
+  ))
 ORDER BY posts.published_at DESC
-LIMIT $2
+LIMIT $4
 `
 
 type GetPostsByUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID   uuid.UUID
+	Author   sql.NullString
+	Category sql.NullString
+	MaxPosts int32
 }
 
+// Author and category filters are optional and case insensitive.
 func (q *Queries) GetPostsByUser(ctx context.Context, arg GetPostsByUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsByUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsByUser,
+		arg.UserID,
+		arg.Author,
+		arg.Category,
+		arg.MaxPosts,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +177,11 @@ func (q *Queries) GetPostsByUser(ctx context.Context, arg GetPostsByUserParams)
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.PublishedAtEstimated,
+			&i.Content,
+			&i.Excerpt,
+			&i.Guid,
+			&i.ContentHash,
 		); err != nil {
 			return nil, err
 		}
@@ -122,3 +195,77 @@ func (q *Queries) GetPostsByUser(ctx context.Context, arg GetPostsByUserParams)
 	}
 	return items, nil
 }
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+  SET
+    feed_id = $1,
+    updated_at = NOW()
+  WHERE feed_id = $2
+`
+
+type MovePostsParams struct {
+	TargetFeedID uuid.UUID
+	SourceFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.TargetFeedID, arg.SourceFeedID)
+	return err
+}
+
+const updatePostContent = `-- name: UpdatePostContent :one
+UPDATE posts
+  SET
+    title = $1,
+    url = $2,
+    guid = $3,
+    description = $4,
+    content = $5,
+    excerpt = $6,
+    content_hash = $7,
+    updated_at = NOW()
+  WHERE id = $8
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_estimated, content, excerpt, guid, content_hash
+`
+
+type UpdatePostContentParams struct {
+	Title       string
+	Url         string
+	Guid        string
+	Description sql.NullString
+	Content     sql.NullString
+	Excerpt     sql.NullString
+	ContentHash sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, updatePostContent,
+		arg.Title,
+		arg.Url,
+		arg.Guid,
+		arg.Description,
+		arg.Content,
+		arg.Excerpt,
+		arg.ContentHash,
+		arg.ID,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.PublishedAtEstimated,
+		&i.Content,
+		&i.Excerpt,
+		&i.Guid,
+		&i.ContentHash,
+	)
+	return i, err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"strconv"
+	"strings"
+)
+
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Language    string           `json:"language"`
+	Icon        string           `json:"icon"`
+	Favicon     string           `json:"favicon"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Hubs        []JSONFeedHub    `json:"hubs"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Avatar string `json:"avatar"`
+}
+
+type JSONFeedItem struct {
+	ID            jsonFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Author        *JSONFeedAuthor      `json:"author"` // deprecated in version 1.1, but still used by 1.0 feeds
+	Tags          []string             `json:"tags"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+// jsonFeedID holds an item ID. The spec requires a string, but numeric IDs are common enough in the wild to be accepted as well.
+type jsonFeedID string
+
+func (id *jsonFeedID) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*id = jsonFeedID(value)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*id = jsonFeedID(number.String())
+	return nil
+}
+
+// authorNames returns the names of the item authors, falling back to the feed authors when the item has none.
+func (item JSONFeedItem) authorNames(feedAuthors []JSONFeedAuthor) []string {
+	authors := item.Authors
+	if len(authors) == 0 && item.Author != nil {
+		authors = []JSONFeedAuthor{*item.Author}
+	}
+	if len(authors) == 0 {
+		authors = feedAuthors
+	}
+
+	names := []string{}
+	for _, author := range authors {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		}
+	}
+	return names
+}
+
+// enclosures maps the item attachments into enclosures, keeping their optional size and duration.
+func (item JSONFeedItem) enclosures() []RSSEnclosure {
+	enclosures := []RSSEnclosure{}
+	for _, attachment := range item.Attachments {
+		enclosure := RSSEnclosure{URL: attachment.URL, Type: attachment.MimeType}
+		if attachment.SizeInBytes > 0 {
+			enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+		}
+		if attachment.DurationInSeconds > 0 {
+			enclosure.Duration = strconv.FormatFloat(attachment.DurationInSeconds, 'f', -1, 64)
+		}
+		enclosures = append(enclosures, enclosure)
+	}
+	return enclosures
+}
+
+// plainTextToHTML escapes plain text and wraps each of its paragraphs, separated by blank lines, in a <p> element.
+func plainTextToHTML(text string) string {
+	var b strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>"))
+		b.WriteString("</p>")
+	}
+	return b.String()
+}
+
+// toRSSFeed maps the JSON feed into the RSSFeed representation used by the crawler.
+func (f JSONFeed) toRSSFeed() RSSFeed {
+	items := make([]RSSFeedItem, 0, len(f.Items))
+	for _, item := range f.Items {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+		// Summary is optional. Content is not copied into the description when it is missing,
+		// as that would store the same body twice, while the excerpt is derived from the content anyway.
+		// Both summary and content_text are plain text, so they are escaped before being stored as markup
+		description := html.EscapeString(strings.TrimSpace(item.Summary))
+		content := item.ContentHTML
+		if content == "" {
+			content = plainTextToHTML(item.ContentText)
+		}
+		authors := item.authorNames(f.Authors)
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+		items = append(items, RSSFeedItem{
+			GUID:        strings.TrimSpace(string(item.ID)),
+			Title:       strings.TrimSpace(item.Title),
+			Link:        link,
+			Description: description,
+			PubDate:     pubDate,
+			Author:      strings.Join(authors, ", "),
+			Creators:    authors,
+			Categories:  item.Tags,
+			Content:     content,
+			Enclosures:  item.enclosures(),
+		})
+	}
+
+	image := f.Icon
+	if image == "" {
+		image = f.Favicon
+	}
+
+	return RSSFeed{
+		Channel: RSSChannel{
+			Title:       strings.TrimSpace(f.Title),
+			AtomLinks:   f.websubLinks(),
+			Link:        f.HomePageURL,
+			Description: f.Description,
+			Language:    f.Language,
+			Image:       RSSImage{URL: image},
+			Item:        items,
+		},
+	}
+}
+
+// websubLinks maps the WebSub hubs of the feed, along with its own URL, into Atom hub and self links.
+func (f JSONFeed) websubLinks() []AtomLink {
+	links := []AtomLink{}
+	if f.FeedURL != "" {
+		links = append(links, AtomLink{Href: f.FeedURL, Rel: "self"})
+	}
+	for _, hub := range f.Hubs {
+		if strings.EqualFold(hub.Type, "WebSub") && hub.URL != "" {
+			links = append(links, AtomLink{Href: hub.URL, Rel: "hub"})
+		}
+	}
+	return links
+}
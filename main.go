@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/deadpyxel/curator/internal/database"
@@ -14,9 +15,23 @@ import (
 )
 
 type apiConfig struct {
-	DB *database.Queries
+	DB      *database.Queries
+	Crawler *crawler
+	Fetcher Fetcher
+	// RefreshLimiter limits how often each user can fetch feeds on demand
+	RefreshLimiter *userRateLimiter
 }
 
+// defaultMaxFeedFailures is the number of consecutive failed fetches after which a feed is disabled.
+// With the exponential backoff this means a feed is disabled after failing for about three weeks.
+const defaultMaxFeedFailures = 30
+
+// Each user can refresh up to refreshRateLimit feeds per refreshRateWindow, as refreshes skip the crawler schedule.
+const (
+	refreshRateLimit  = 5
+	refreshRateWindow = time.Minute
+)
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
@@ -38,14 +53,46 @@ func main() {
 		logger.Fatal("Failed to connect to the database", "error", err)
 	}
 
+	maxFeedFailures := defaultMaxFeedFailures
+	if maxFailuresStr := os.Getenv("FEED_MAX_FAILURES"); maxFailuresStr != "" {
+		maxFeedFailures, err = strconv.Atoi(maxFailuresStr)
+		if err != nil {
+			logger.Fatal("FEED_MAX_FAILURES is not a valid number", "error", err)
+		}
+	}
+
+	fetcherCfg, err := fetcherConfigFromEnv()
+	if err != nil {
+		logger.Fatal("Invalid feed fetcher configuration", "error", err)
+	}
+	feedFetcher := newHTTPFetcher(fetcherCfg)
+	web := feedFetcher.webClient()
+
+	politenessCfg, err := politenessConfigFromEnv()
+	if err != nil {
+		logger.Fatal("Invalid crawler politeness configuration", "error", err)
+	}
+
+	// WebSub needs the public URL of the API for hubs to call back, feeds are only polled without it
+	var websub *websubClient
+	if callbackURL := os.Getenv("WEBSUB_CALLBACK_URL"); callbackURL != "" {
+		websub = newWebSubClient(callbackURL, web)
+	}
+
 	dbQueries := database.New(dbConn)
+	// The crawler goes through the host limits, both for feeds and for the requests made around them
+	politeFetcher := newPoliteFetcher(feedFetcher, web, politenessCfg)
+	feedCrawler := newCrawler(dbConn, politeFetcher, web.withHostLimits(politeFetcher.hosts), websub, int32(maxFeedFailures))
 
 	apiCfg := apiConfig{
-		DB: dbQueries,
+		DB:             dbQueries,
+		Crawler:        feedCrawler,
+		Fetcher:        feedFetcher,
+		RefreshLimiter: newUserRateLimiter(refreshRateLimit, refreshRateWindow),
 	}
 
 	// Start scrapping feed data
-	go startFeedScrapping(dbQueries, 8, time.Minute)
+	go feedCrawler.startFeedScrapping(8, time.Minute)
 
 	mux := http.NewServeMux()
 
@@ -58,11 +105,18 @@ func main() {
 	// Feeds
 	mux.HandleFunc("POST /v1/feeds", apiCfg.authMiddleware(apiCfg.handlerCreateFeed))
 	mux.HandleFunc("GET /v1/feeds", apiCfg.handlerGetFeeds)
+	mux.HandleFunc("POST /v1/feeds/{feedID}/enable", apiCfg.authMiddleware(apiCfg.handlerEnableFeed))
+	mux.HandleFunc("POST /v1/feeds/{feedID}/refresh", apiCfg.authMiddleware(apiCfg.handlerRefreshFeed))
+	mux.HandleFunc("GET /v1/feeds/{feedID}/fetches", apiCfg.authMiddleware(apiCfg.handlerGetFeedFetches))
 	mux.HandleFunc("POST /v1/feed_follows", apiCfg.authMiddleware(apiCfg.handlerCreateFeedFollow))
 	mux.HandleFunc("GET /v1/feed_follows", apiCfg.authMiddleware(apiCfg.handlerGetFeedFollows))
 	mux.HandleFunc("DELETE /v1/feed_follows/{feedFollowID}", apiCfg.authMiddleware(apiCfg.handlerDeleteFeedFollow))
 	// Posts
 	mux.HandleFunc("GET /v1/posts", apiCfg.authMiddleware(apiCfg.handlerGetPostsByUser))
+	mux.HandleFunc("GET /v1/posts/{postID}/revisions", apiCfg.authMiddleware(apiCfg.handlerGetPostRevisions))
+	// WebSub callbacks, called by hubs
+	mux.HandleFunc("GET /v1/websub/{feedID}", apiCfg.handlerWebSubVerify)
+	mux.HandleFunc("POST /v1/websub/{feedID}", apiCfg.handlerWebSubPush)
 	logMux := logMiddleware(mux)
 
 	httpServer := &http.Server{
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RSSEnclosure is a media file attached to an item, like a podcast episode.
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+	// Duration is not part of RSS enclosures, but is provided by other feed formats mapped into them
+	Duration string `xml:"-"`
+}
+
+// MediaContent is a media:content element from the Media RSS namespace.
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// MediaGroup is a media:group element, holding alternative versions of the same media.
+type MediaGroup struct {
+	Content []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// mediaEnclosure is an item enclosure, normalised from the different ways feeds provide media files.
+// Zero values mean the feed did not provide the information.
+type mediaEnclosure struct {
+	URL             string
+	MimeType        string
+	Length          int64
+	DurationSeconds int32
+}
+
+// mediaEnclosures collects the media files of an item from <enclosure>, media:content and media:group elements.
+// The iTunes duration describes the episode, so it is used for enclosures without their own duration.
+// Relative URLs are resolved against the item link, falling back to feedURL, and only http and https URLs are kept,
+// as enclosures are served to clients just like the links of post bodies.
+func (item RSSFeedItem) mediaEnclosures(feedURL *url.URL) []mediaEnclosure {
+	baseURL := feedURL
+	if baseURL == nil {
+		baseURL = &url.URL{}
+	}
+	if itemURL, err := baseURL.Parse(strings.TrimSpace(item.Link)); err == nil && itemURL.IsAbs() {
+		baseURL = itemURL
+	}
+
+	enclosures := []mediaEnclosure{}
+	seen := map[string]bool{}
+	add := func(rawURL, mimeType, length, duration string) {
+		rawURL = resolveWebURL(baseURL, rawURL)
+		if rawURL == "" || seen[rawURL] {
+			return
+		}
+		seen[rawURL] = true
+
+		enclosure := mediaEnclosure{URL: rawURL, MimeType: strings.TrimSpace(mimeType)}
+		if parsed, err := strconv.ParseInt(strings.TrimSpace(length), 10, 64); err == nil && parsed > 0 {
+			enclosure.Length = parsed
+		}
+		if seconds, ok := parseMediaDuration(duration); ok {
+			enclosure.DurationSeconds = seconds
+		} else if seconds, ok := parseMediaDuration(item.ITunesDuration); ok {
+			enclosure.DurationSeconds = seconds
+		}
+		enclosures = append(enclosures, enclosure)
+	}
+
+	for _, enclosure := range item.Enclosures {
+		add(enclosure.URL, enclosure.Type, enclosure.Length, enclosure.Duration)
+	}
+	for _, content := range item.MediaContent {
+		add(content.URL, content.Type, content.FileSize, content.Duration)
+	}
+	for _, group := range item.MediaGroups {
+		for _, content := range group.Content {
+			add(content.URL, content.Type, content.FileSize, content.Duration)
+		}
+	}
+	return enclosures
+}
+
+// parseMediaDuration parses a duration into seconds.
+// Durations can be given in seconds or as MM:SS and HH:MM:SS, with optional fractional seconds.
+func parseMediaDuration(value string) (int32, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+
+	total := 0.0
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return 0, false
+		}
+		// Only the seconds can be fractional
+		if i < len(parts)-1 && number != float64(int64(number)) {
+			return 0, false
+		}
+		total = total*60 + number
+	}
+	if total <= 0 || total > float64(1<<31-1) {
+		return 0, false
+	}
+	return int32(total), true
+}
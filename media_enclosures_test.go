@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const podcastDocument = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Podcast</title>
+    <link>https://example.com/</link>
+    <item>
+      <title>Episode 1</title>
+      <link>https://example.com/episodes/1</link>
+      <enclosure url="https://cdn.example.com/ep1.mp3" type="audio/mpeg" length="12345678"/>
+      <itunes:duration>1:02:03</itunes:duration>
+      <media:content url="https://cdn.example.com/ep1.mp3" type="audio/mpeg"/>
+      <media:content url="https://cdn.example.com/ep1.ogg" type="audio/ogg" fileSize="2048" duration="120"/>
+      <media:group>
+        <media:content url="https://cdn.example.com/ep1.mp4" type="video/mp4"/>
+      </media:group>
+    </item>
+  </channel>
+</rss>`
+
+func TestMediaEnclosures(t *testing.T) {
+	feed, err := parseFeed("application/rss+xml", strings.NewReader(podcastDocument))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(feed.Channel.Item))
+	}
+
+	expected := []mediaEnclosure{
+		{URL: "https://cdn.example.com/ep1.mp3", MimeType: "audio/mpeg", Length: 12345678, DurationSeconds: 3723},
+		{URL: "https://cdn.example.com/ep1.ogg", MimeType: "audio/ogg", Length: 2048, DurationSeconds: 120},
+		{URL: "https://cdn.example.com/ep1.mp4", MimeType: "video/mp4", DurationSeconds: 3723},
+	}
+	enclosures := feed.Channel.Item[0].mediaEnclosures(nil)
+	if len(enclosures) != len(expected) {
+		t.Fatalf("Expected %d enclosures, got %d: %+v", len(expected), len(enclosures), enclosures)
+	}
+	for i := range expected {
+		if enclosures[i] != expected[i] {
+			t.Errorf("Expected enclosure %+v, got %+v", expected[i], enclosures[i])
+		}
+	}
+}
+
+func TestMediaEnclosuresFromOtherFormats(t *testing.T) {
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <title>Episode</title>
+    <link href="https://example.com/episode"/>
+    <link rel="enclosure" href="https://cdn.example.com/episode.mp3" type="audio/mpeg" length="1024"/>
+    <updated>2024-01-01T10:00:00Z</updated>
+  </entry>
+</feed>`
+	jsonFeed := `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "items": [{
+    "id": "1",
+    "url": "https://example.com/episode",
+    "attachments": [{"url": "https://cdn.example.com/episode.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1024, "duration_in_seconds": 90.5}]
+  }]
+}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		document    string
+		expected    mediaEnclosure
+	}{
+		{
+			name:        "Atom enclosure link",
+			contentType: "application/atom+xml",
+			document:    atom,
+			expected:    mediaEnclosure{URL: "https://cdn.example.com/episode.mp3", MimeType: "audio/mpeg", Length: 1024},
+		},
+		{
+			name:        "JSON Feed attachment",
+			contentType: "application/feed+json",
+			document:    jsonFeed,
+			expected:    mediaEnclosure{URL: "https://cdn.example.com/episode.mp3", MimeType: "audio/mpeg", Length: 1024, DurationSeconds: 90},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, strings.NewReader(tt.document))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			enclosures := feed.Channel.Item[0].mediaEnclosures(nil)
+			if len(enclosures) != 1 {
+				t.Fatalf("Expected 1 enclosure, got %d: %+v", len(enclosures), enclosures)
+			}
+			if enclosures[0] != tt.expected {
+				t.Errorf("Expected enclosure %+v, got %+v", tt.expected, enclosures[0])
+			}
+		})
+	}
+}
+
+func TestMediaEnclosuresURLs(t *testing.T) {
+	feedURL, _ := url.Parse("https://example.com/feeds/podcast.xml")
+	tests := []struct {
+		name     string
+		link     string
+		url      string
+		expected string
+	}{
+		{name: "Absolute URL", link: "https://example.com/episodes/1", url: "https://cdn.example.com/ep1.mp3", expected: "https://cdn.example.com/ep1.mp3"},
+		{name: "Relative to item link", link: "https://example.com/episodes/1", url: "media/ep1.mp3", expected: "https://example.com/episodes/media/ep1.mp3"},
+		{name: "Relative to feed URL without item link", url: "/media/ep1.mp3", expected: "https://example.com/media/ep1.mp3"},
+		{name: "Relative item link", link: "/episodes/1", url: "ep1.mp3", expected: "https://example.com/episodes/ep1.mp3"},
+		{name: "JavaScript URL", link: "https://example.com/episodes/1", url: "javascript:alert(1)"},
+		{name: "Data URL", link: "https://example.com/episodes/1", url: "data:audio/mpeg;base64,AAAA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := RSSFeedItem{Link: tt.link, Enclosures: []RSSEnclosure{{URL: tt.url, Type: "audio/mpeg"}}}
+			enclosures := item.mediaEnclosures(feedURL)
+			if tt.expected == "" {
+				if len(enclosures) != 0 {
+					t.Errorf("Expected no enclosure, got %+v", enclosures)
+				}
+				return
+			}
+			if len(enclosures) != 1 || enclosures[0].URL != tt.expected {
+				t.Errorf("Expected enclosure URL %q, got %+v", tt.expected, enclosures)
+			}
+		})
+	}
+}
+
+func TestParseMediaDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int32
+		ok       bool
+	}{
+		{"3600", 3600, true},
+		{"90.7", 90, true},
+		{"05:30", 330, true},
+		{"1:02:03", 3723, true},
+		{"", 0, false},
+		{"0", 0, false},
+		{"abc", 0, false},
+		{"1:2:3:4", 0, false},
+		{"1.5:30", 0, false},
+		{"-10", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, ok := parseMediaDuration(tt.input)
+			if result != tt.expected || ok != tt.ok {
+				t.Errorf("Expected (%d, %t), got (%d, %t)", tt.expected, tt.ok, result, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConnsPerHost = 2
+	defaultHostDelay       = time.Second
+	// maxCrawlDelay caps the Crawl-delay requested by robots.txt, so feeds of hosts asking for long delays are still fetched
+	maxCrawlDelay = time.Minute
+	// maxHostWait is the longest a crawler fetch waits for its host. Fetches that would wait longer are rescheduled,
+	// as the crawler waits for a whole batch of feeds, so a slow host would hold up the feeds of every other host.
+	maxHostWait = 5 * time.Second
+	// hostEvictionInterval is how often the hosts without pending requests are removed from the limiter
+	hostEvictionInterval = 10 * time.Minute
+)
+
+// politenessConfig holds the limits applied by the crawler to each host it fetches feeds from.
+type politenessConfig struct {
+	// MaxConnsPerHost is the number of feeds fetched at the same time from a single host
+	MaxConnsPerHost int
+	// HostDelay is the minimum time between the start of two requests to the same host
+	HostDelay time.Duration
+	// RespectRobots makes the crawler skip feeds disallowed by the robots.txt of their host
+	RespectRobots bool
+}
+
+func defaultPolitenessConfig() politenessConfig {
+	return politenessConfig{
+		MaxConnsPerHost: defaultMaxConnsPerHost,
+		HostDelay:       defaultHostDelay,
+	}
+}
+
+// politenessConfigFromEnv reads the politeness settings from the FEED_* environment variables.
+// Settings that are not defined keep their default values.
+func politenessConfigFromEnv() (politenessConfig, error) {
+	config := defaultPolitenessConfig()
+
+	if connsStr := os.Getenv("FEED_MAX_CONNS_PER_HOST"); connsStr != "" {
+		conns, err := strconv.Atoi(connsStr)
+		if err != nil || conns <= 0 {
+			return politenessConfig{}, fmt.Errorf("FEED_MAX_CONNS_PER_HOST is not a valid number: %q", connsStr)
+		}
+		config.MaxConnsPerHost = conns
+	}
+	if delayStr := os.Getenv("FEED_HOST_DELAY"); delayStr != "" {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil || delay < 0 {
+			return politenessConfig{}, fmt.Errorf("FEED_HOST_DELAY is not a valid duration: %q", delayStr)
+		}
+		config.HostDelay = delay
+	}
+	if robotsStr := os.Getenv("FEED_RESPECT_ROBOTS"); robotsStr != "" {
+		respectRobots, err := strconv.ParseBool(robotsStr)
+		if err != nil {
+			return politenessConfig{}, fmt.Errorf("FEED_RESPECT_ROBOTS is not a valid boolean: %q", robotsStr)
+		}
+		config.RespectRobots = respectRobots
+	}
+
+	return config, nil
+}
+
+// hostLimiter limits the number of concurrent requests to each host and spaces out the requests made to it.
+type hostLimiter struct {
+	maxPerHost int
+	minDelay   time.Duration
+	// maxWait is the longest a request waits for its start time, zero meaning no limit
+	maxWait time.Duration
+
+	mu           sync.Mutex
+	hosts        map[string]*hostSlots
+	lastEviction time.Time
+}
+
+type hostSlots struct {
+	slots chan struct{}
+	// next and users are guarded by hostLimiter.mu
+	next  time.Time // earliest start of the next request
+	users int       // requests holding or waiting for a slot
+}
+
+// hostBusyError is returned when a request would wait for its host longer than the limiter allows.
+type hostBusyError struct {
+	Host    string
+	RetryAt time.Time // when the host is free again
+}
+
+func (e *hostBusyError) Error() string {
+	return fmt.Sprintf("host %s is busy until %s", e.Host, e.RetryAt.Format(time.RFC3339))
+}
+
+func newHostLimiter(maxPerHost int, minDelay, maxWait time.Duration) *hostLimiter {
+	return &hostLimiter{
+		maxPerHost: maxPerHost,
+		minDelay:   minDelay,
+		maxWait:    maxWait,
+		hosts:      map[string]*hostSlots{},
+	}
+}
+
+// acquire waits until a request to host may start, spacing it by at least delay or the limiter minimum, whichever is larger.
+// The returned function must be called once the request is done, to let the next request to the host in.
+// A hostBusyError is returned when the request could only start after the maximum wait, either because the host
+// has no free slot by then or because of the spacing of its requests.
+func (l *hostLimiter) acquire(ctx context.Context, host string, delay time.Duration) (func(), error) {
+	l.mu.Lock()
+	l.evictIdle(time.Now())
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostSlots{slots: make(chan struct{}, l.maxPerHost)}
+		l.hosts[host] = h
+	}
+	h.users++
+	l.mu.Unlock()
+	done := func() {
+		l.mu.Lock()
+		h.users--
+		l.mu.Unlock()
+	}
+
+	// The maximum wait covers waiting for a free slot as well, as slow requests can hold the slots of a host for long
+	var deadline time.Time
+	var slotWait <-chan time.Time
+	if l.maxWait > 0 {
+		deadline = time.Now().Add(l.maxWait)
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		slotWait = timer.C
+	}
+	select {
+	case h.slots <- struct{}{}:
+	case <-slotWait:
+		done()
+		// There is no telling when a slot is freed, so the request is retried once it could have waited again
+		return nil, &hostBusyError{Host: host, RetryAt: time.Now().Add(l.maxWait)}
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+	release := func() {
+		<-h.slots
+		done()
+	}
+
+	// Start times are reserved in order, so requests waiting for the same host keep their spacing
+	l.mu.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	if l.maxWait > 0 && start.After(deadline) {
+		l.mu.Unlock()
+		release()
+		return nil, &hostBusyError{Host: host, RetryAt: start}
+	}
+	h.next = start.Add(max(delay, l.minDelay))
+	l.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// evictIdle removes the hosts without pending requests whose spacing is over, so the map does not grow with every host
+// ever fetched. It only scans the hosts once per hostEvictionInterval. The caller must hold l.mu.
+func (l *hostLimiter) evictIdle(now time.Time) {
+	if now.Sub(l.lastEviction) < hostEvictionInterval {
+		return
+	}
+	l.lastEviction = now
+	for host, h := range l.hosts {
+		if h.users == 0 && !h.next.After(now) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+var (
+	errDisallowedByRobots = errors.New("disallowed by robots.txt")
+	errRobotsUnavailable  = errors.New("robots.txt unavailable")
+)
+
+// politeFetcher wraps a Fetcher so feeds hosted together are not fetched all at once.
+// It is used by the crawler, while feeds requested by users are fetched directly.
+type politeFetcher struct {
+	fetcher Fetcher
+	hosts   *hostLimiter
+	robots  *robotsCache // nil when robots.txt is not honoured
+}
+
+func newPoliteFetcher(fetcher Fetcher, web *webClient, config politenessConfig) *politeFetcher {
+	polite := &politeFetcher{
+		fetcher: fetcher,
+		hosts:   newHostLimiter(config.MaxConnsPerHost, config.HostDelay, maxHostWait),
+	}
+	if config.RespectRobots {
+		polite.robots = newRobotsCache(web)
+	}
+	return polite
+}
+
+// Fetch implements Fetcher.
+func (f *politeFetcher) Fetch(ctx context.Context, rawURL string, validators cacheValidators) (feedResponse, error) {
+	feedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return feedResponse{}, err
+	}
+
+	var delay time.Duration
+	if f.robots != nil {
+		rules := f.robots.rules(ctx, feedURL)
+		// Servers failing to serve their robots.txt may be overloaded, so nothing is fetched from them for now
+		if rules.unavailable {
+			return feedResponse{}, fmt.Errorf("%w: %s", errRobotsUnavailable, feedURL.Host)
+		}
+		if !rules.allowed(feedURL) {
+			return feedResponse{}, fmt.Errorf("%w: %s", errDisallowedByRobots, rawURL)
+		}
+		delay = min(rules.crawlDelay, maxCrawlDelay)
+	}
+
+	release, err := f.hosts.acquire(ctx, strings.ToLower(feedURL.Host), delay)
+	if err != nil {
+		return feedResponse{}, err
+	}
+	defer release()
+
+	return f.fetcher.Fetch(ctx, rawURL, validators)
+}
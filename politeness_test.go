@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterConcurrency(t *testing.T) {
+	limiter := newHostLimiter(2, 0, 0)
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := limiter.acquire(context.Background(), "example.com", 0)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+			mu.Lock()
+			active++
+			maxActive = max(maxActive, active)
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", maxActive)
+	}
+}
+
+func TestHostLimiterSpacing(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	limiter := newHostLimiter(4, delay, 0)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := limiter.acquire(context.Background(), "example.com", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		release()
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Errorf("Expected requests to be spaced by %v, took %v for 3 requests", delay, elapsed)
+	}
+
+	t.Run("Other hosts are not delayed", func(t *testing.T) {
+		start := time.Now()
+		release, err := limiter.acquire(context.Background(), "other.example.com", 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		release()
+		if elapsed := time.Since(start); elapsed >= delay {
+			t.Errorf("Expected no wait for another host, waited %v", elapsed)
+		}
+	})
+
+	t.Run("Cancelled wait", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := limiter.acquire(ctx, "example.com", time.Hour); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context cancelled error, got %v", err)
+		}
+	})
+}
+
+func TestHostLimiterMaxWait(t *testing.T) {
+	limiter := newHostLimiter(4, time.Hour, time.Second)
+
+	release, err := limiter.acquire(context.Background(), "example.com", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	release()
+
+	start := time.Now()
+	_, err = limiter.acquire(context.Background(), "example.com", 0)
+	var busy *hostBusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Expected host busy error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected busy hosts not to be waited for, waited %v", elapsed)
+	}
+	if until := time.Until(busy.RetryAt); until < 59*time.Minute {
+		t.Errorf("Expected retry once the host delay is over, got %v from now", until)
+	}
+}
+
+func TestHostLimiterMaxWaitForSlot(t *testing.T) {
+	limiter := newHostLimiter(1, 0, 50*time.Millisecond)
+
+	release, err := limiter.acquire(context.Background(), "example.com", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer release()
+
+	start := time.Now()
+	_, err = limiter.acquire(context.Background(), "example.com", 0)
+	var busy *hostBusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Expected host busy error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("Expected the wait for a slot to be bounded by the maximum wait, waited %v", elapsed)
+	}
+
+	limiter.mu.Lock()
+	users := limiter.hosts["example.com"].users
+	limiter.mu.Unlock()
+	if users != 1 {
+		t.Errorf("Expected only the request holding the slot to be counted, got %d", users)
+	}
+}
+
+func TestHostLimiterEviction(t *testing.T) {
+	limiter := newHostLimiter(1, 0, 0)
+	release, err := limiter.acquire(context.Background(), "busy.example.com", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	idle, err := limiter.acquire(context.Background(), "idle.example.com", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	idle()
+
+	limiter.mu.Lock()
+	limiter.evictIdle(time.Now().Add(hostEvictionInterval))
+	_, busyKept := limiter.hosts["busy.example.com"]
+	_, idleKept := limiter.hosts["idle.example.com"]
+	limiter.mu.Unlock()
+	release()
+
+	if !busyKept {
+		t.Error("Expected hosts with pending requests to be kept")
+	}
+	if idleKept {
+		t.Error("Expected idle hosts to be evicted")
+	}
+}
+
+func TestPoliteFetcherRobots(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+	}))
+	defer server.Close()
+
+	inner := &fakeFetcher{feeds: map[string]RSSFeed{
+		server.URL + "/feed.xml":         {Channel: RSSChannel{Title: "Public"}},
+		server.URL + "/private/feed.xml": {Channel: RSSChannel{Title: "Private"}},
+	}}
+	config := politenessConfig{MaxConnsPerHost: 1, RespectRobots: true}
+	fetcher := newPoliteFetcher(inner, newHTTPFetcher(defaultFetcherConfig()).webClient(), config)
+
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/feed.xml", cacheValidators{}); err != nil {
+		t.Errorf("Expected allowed feed to be fetched, got %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/private/feed.xml", cacheValidators{}); !errors.Is(err, errDisallowedByRobots) {
+		t.Errorf("Expected disallowed error, got %v", err)
+	}
+	if len(inner.requested) != 1 {
+		t.Errorf("Expected only the allowed feed to be requested, got %q", inner.requested)
+	}
+}
+
+func TestPoliteFetcherRobotsServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	inner := &fakeFetcher{feeds: map[string]RSSFeed{server.URL + "/feed.xml": {Channel: RSSChannel{Title: "Feed"}}}}
+	config := politenessConfig{MaxConnsPerHost: 1, RespectRobots: true}
+	fetcher := newPoliteFetcher(inner, newHTTPFetcher(defaultFetcherConfig()).webClient(), config)
+
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/feed.xml", cacheValidators{}); !errors.Is(err, errRobotsUnavailable) {
+		t.Errorf("Expected robots.txt unavailable error, got %v", err)
+	}
+	if len(inner.requested) != 0 {
+		t.Errorf("Expected nothing to be fetched, got %q", inner.requested)
+	}
+}
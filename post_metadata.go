@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// postAuthors returns the author names of an item.
+// RSS authors are e-mail addresses optionally followed by the name in parentheses, so only the name is kept.
+func (item RSSFeedItem) postAuthors() []string {
+	if len(item.Creators) > 0 {
+		return normaliseNames(item.Creators)
+	}
+	return normaliseNames([]string{rssAuthorName(item.Author)})
+}
+
+// postCategories returns the category names of an item.
+func (item RSSFeedItem) postCategories() []string {
+	return normaliseNames(item.Categories)
+}
+
+// rssAuthorName extracts the name from an RSS author like "jane@example.com (Jane Doe)".
+// Addresses without a name return an empty string, as e-mail addresses are not shown as author names.
+// Authors that are not addresses, which feeds commonly use despite the specification, are kept as they are.
+func rssAuthorName(author string) string {
+	author = strings.TrimSpace(author)
+	if address, err := mail.ParseAddress(author); err == nil {
+		return strings.TrimSpace(address.Name)
+	}
+	return author
+}
+
+// normaliseNames collapses whitespace in the names and removes empty and duplicated ones, ignoring case.
+func normaliseNames(values []string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		name := strings.Join(strings.Fields(value), " ")
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names
+}
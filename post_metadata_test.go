@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPostAuthorsAndCategories(t *testing.T) {
+	rss := `<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <item>
+      <title>With author</title>
+      <author>jane@example.com (Jane Doe)</author>
+      <category>Go</category>
+      <category domain="https://example.com/tags">go</category>
+      <category> Web   Development </category>
+    </item>
+    <item>
+      <title>With creators</title>
+      <author>editor@example.com</author>
+      <dc:creator>Jane Doe</dc:creator>
+      <dc:creator>John Roe</dc:creator>
+    </item>
+  </channel>
+</rss>`
+	atom := `<feed xmlns="http://www.w3.org/2005/Atom">
+  <author><name>Feed Author</name></author>
+  <entry>
+    <title>Entry</title>
+    <author><name>Jane Doe</name></author>
+    <category term="golang" label="Go"/>
+    <category term="web"/>
+  </entry>
+  <entry>
+    <title>Entry without author</title>
+  </entry>
+</feed>`
+	jsonFeed := `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "items": [{"id": "1", "authors": [{"name": "Jane Doe"}], "tags": ["Go", "Web"]}]
+}`
+
+	tests := []struct {
+		name               string
+		contentType        string
+		document           string
+		item               int
+		expectedAuthors    []string
+		expectedCategories []string
+	}{
+		{"RSS author with name", "application/rss+xml", rss, 0, []string{"Jane Doe"}, []string{"Go", "Web Development"}},
+		{"RSS creators", "application/rss+xml", rss, 1, []string{"Jane Doe", "John Roe"}, []string{}},
+		{"Atom entry", "application/atom+xml", atom, 0, []string{"Jane Doe"}, []string{"Go", "web"}},
+		{"Atom feed author fallback", "application/atom+xml", atom, 1, []string{"Feed Author"}, []string{}},
+		{"JSON Feed", "application/feed+json", jsonFeed, 0, []string{"Jane Doe"}, []string{"Go", "Web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, strings.NewReader(tt.document))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			item := feed.Channel.Item[tt.item]
+			if authors := item.postAuthors(); !reflect.DeepEqual(authors, tt.expectedAuthors) {
+				t.Errorf("Expected authors %q, got %q", tt.expectedAuthors, authors)
+			}
+			if categories := item.postCategories(); !reflect.DeepEqual(categories, tt.expectedCategories) {
+				t.Errorf("Expected categories %q, got %q", tt.expectedCategories, categories)
+			}
+		})
+	}
+}
+
+func TestRSSAuthorName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"jane@example.com (Jane Doe)", "Jane Doe"},
+		{"Jane Doe <jane@example.com>", "Jane Doe"},
+		{"jane@example.com", ""},
+		{"Jane Doe", "Jane Doe"},
+		{"jane@example.com ()", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := rssAuthorName(tt.input); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userRateLimiter allows each user a number of requests within a sliding window.
+type userRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu           sync.Mutex
+	requests     map[uuid.UUID][]time.Time
+	lastEviction time.Time
+}
+
+func newUserRateLimiter(limit int, window time.Duration) *userRateLimiter {
+	return &userRateLimiter{
+		limit:    limit,
+		window:   window,
+		requests: map[uuid.UUID][]time.Time{},
+	}
+}
+
+// allow records a request by the user at now, unless the user already reached the limit.
+// When the request is refused, retryAfter is the wait until the user may try again.
+func (l *userRateLimiter) allow(userID uuid.UUID, now time.Time) (retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictStale(now)
+	// Requests are kept in order, so the ones that left the window are at the start
+	recent := l.requests[userID]
+	for len(recent) > 0 && !recent[0].After(now.Add(-l.window)) {
+		recent = recent[1:]
+	}
+	if len(recent) >= l.limit {
+		l.requests[userID] = recent
+		return recent[0].Add(l.window).Sub(now), false
+	}
+	l.requests[userID] = append(recent, now)
+	return 0, true
+}
+
+// evictStale removes the users whose requests all left the window, so the map does not grow with every user
+// who ever made a request. It only scans the users once per window. The caller must hold l.mu.
+func (l *userRateLimiter) evictStale(now time.Time) {
+	if now.Sub(l.lastEviction) < l.window {
+		return
+	}
+	l.lastEviction = now
+	for userID, recent := range l.requests {
+		if len(recent) == 0 || !recent[len(recent)-1].After(now.Add(-l.window)) {
+			delete(l.requests, userID)
+		}
+	}
+}
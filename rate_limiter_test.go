@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUserRateLimiter(t *testing.T) {
+	limiter := newUserRateLimiter(2, time.Minute)
+	user, otherUser := uuid.New(), uuid.New()
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name               string
+		userID             uuid.UUID
+		at                 time.Duration
+		expectedOK         bool
+		expectedRetryAfter time.Duration
+	}{
+		{"First request", user, 0, true, 0},
+		{"Second request", user, 10 * time.Second, true, 0},
+		{"Over the limit", user, 20 * time.Second, false, 40 * time.Second},
+		{"Other users are not limited", otherUser, 20 * time.Second, true, 0},
+		{"Oldest request left the window", user, time.Minute, true, 0},
+		{"Over the limit again", user, 65 * time.Second, false, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryAfter, ok := limiter.allow(tt.userID, start.Add(tt.at))
+			if ok != tt.expectedOK || retryAfter != tt.expectedRetryAfter {
+				t.Errorf("Expected (%v, %t), got (%v, %t)", tt.expectedRetryAfter, tt.expectedOK, retryAfter, ok)
+			}
+		})
+	}
+}
+
+func TestUserRateLimiterEviction(t *testing.T) {
+	limiter := newUserRateLimiter(2, time.Minute)
+	active, idle := uuid.New(), uuid.New()
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	limiter.allow(idle, start)
+	limiter.allow(active, start.Add(30*time.Second))
+	limiter.allow(active, start.Add(time.Minute+10*time.Second))
+
+	limiter.mu.Lock()
+	_, activeKept := limiter.requests[active]
+	_, idleKept := limiter.requests[idle]
+	limiter.mu.Unlock()
+
+	if !activeKept {
+		t.Error("Expected users with requests in the window to be kept")
+	}
+	if idleKept {
+		t.Error("Expected users without requests in the window to be evicted")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+)
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// RDFFeed represents an RSS 1.0 document. Unlike RSS 2.0, items are siblings of the channel instead of its children.
+// Item metadata, like publication date and author, comes from the Dublin Core namespace.
+type RDFFeed struct {
+	Channel RDFChannel `xml:"channel"`
+	Image   RSSImage   `xml:"image"`
+	Items   []RDFItem  `xml:"item"`
+}
+
+type RDFChannel struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Language    string `xml:"http://purl.org/dc/elements/1.1/ language"`
+}
+
+type RDFItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creators    []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	Content     string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+// toRSSFeed maps the RSS 1.0 feed into the RSSFeed representation used by the crawler.
+func (f RDFFeed) toRSSFeed() RSSFeed {
+	items := make([]RSSFeedItem, 0, len(f.Items))
+	for _, item := range f.Items {
+		items = append(items, RSSFeedItem{
+			GUID:        strings.TrimSpace(item.About),
+			Title:       strings.TrimSpace(item.Title),
+			Link:        strings.TrimSpace(item.Link),
+			Description: strings.TrimSpace(item.Description),
+			PubDate:     strings.TrimSpace(item.Date),
+			Author:      strings.TrimSpace(strings.Join(item.Creators, ", ")),
+			Creators:    item.Creators,
+			Categories:  item.Subjects,
+			Content:     strings.TrimSpace(item.Content),
+		})
+	}
+
+	return RSSFeed{
+		Channel: RSSChannel{
+			Title:       strings.TrimSpace(f.Channel.Title),
+			Link:        strings.TrimSpace(f.Channel.Link),
+			Description: strings.TrimSpace(f.Channel.Description),
+			Language:    strings.TrimSpace(f.Channel.Language),
+			Image:       RSSImage{URL: strings.TrimSpace(f.Image.URL)},
+			Item:        items,
+		},
+	}
+}
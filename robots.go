@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// robotsTTL is how long the robots.txt of a host is cached
+	robotsTTL = 24 * time.Hour
+	// robotsErrorTTL is how long a server error for a robots.txt is cached, before it is requested again
+	robotsErrorTTL = time.Hour
+	// maxRobotsSize is the part of a robots.txt that is parsed, as crawlers are only required to parse 500 KiB
+	maxRobotsSize = 500 << 10
+)
+
+// robotsRule allows or disallows the paths matching its pattern.
+type robotsRule struct {
+	allow   bool
+	length  int // length of the original pattern, the longest matching rule wins
+	pattern *regexp.Regexp
+}
+
+// robotsRules are the robots.txt rules applying to the crawler on a host.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	// unavailable is set when the server failed to serve its robots.txt, in which case nothing may be fetched
+	unavailable bool
+}
+
+// allowed reports if the rules let the crawler fetch u.
+// As in RFC 9309, the longest matching rule wins, and allow rules win ties.
+func (r robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	allowed, matchLength := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > matchLength || (rule.length == matchLength && rule.allow) {
+			allowed, matchLength = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// parseRobots parses a robots.txt, keeping the rules of the groups applying to userAgent.
+// The groups naming the crawler are used when present, otherwise the rules fall back to the "*" group.
+func parseRobots(body io.Reader, userAgent string) robotsRules {
+	product := strings.ToLower(strings.SplitN(strings.TrimSpace(userAgent), "/", 2)[0])
+
+	var specific, wildcard robotsRules
+	var hasSpecific bool
+	// The groups being filled, as consecutive user-agent lines share the rules that follow them
+	var current []*robotsRules
+	readingAgents := false
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !readingAgents {
+				current = nil
+				readingAgents = true
+			}
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				current = append(current, &wildcard)
+			case agent == product:
+				current = append(current, &specific)
+				hasSpecific = true
+			}
+			continue
+		}
+		readingAgents = false
+
+		for _, group := range current {
+			switch key {
+			case "allow", "disallow":
+				if value == "" {
+					continue
+				}
+				group.rules = append(group.rules, robotsRule{
+					allow:   key == "allow",
+					length:  len(value),
+					pattern: robotsPattern(value),
+				})
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if hasSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsPattern compiles a robots.txt path pattern, where * matches any characters and a trailing $ anchors the end.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+type robotsEntry struct {
+	rules   robotsRules
+	expires time.Time
+}
+
+// robotsCache fetches and caches the robots.txt rules of each host.
+type robotsCache struct {
+	web *webClient
+
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}
+
+func newRobotsCache(web *webClient) *robotsCache {
+	return &robotsCache{
+		web:     web,
+		entries: map[string]robotsEntry{},
+	}
+}
+
+// rules returns the robots.txt rules of the host of u, fetching them when they are not cached.
+func (c *robotsCache) rules(ctx context.Context, u *url.URL) robotsRules {
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+
+	c.mu.Lock()
+	entry, ok := c.entries[origin]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rules
+	}
+
+	rules := c.fetch(ctx, origin)
+	ttl := robotsTTL
+	if rules.unavailable {
+		ttl = robotsErrorTTL
+	}
+	c.mu.Lock()
+	c.entries[origin] = robotsEntry{rules: rules, expires: time.Now().Add(ttl)}
+	c.mu.Unlock()
+	return rules
+}
+
+// fetch downloads and parses the robots.txt of origin.
+// A missing robots.txt allows everything, so feeds keep working when the host has none, while server errors
+// disallow everything, as RFC 9309 requires. Unreachable hosts are allowed, so their feeds fail and are backed off as usual.
+func (c *robotsCache) fetch(ctx context.Context, origin string) robotsRules {
+	resp, err := c.web.get(ctx, origin+"/robots.txt")
+	if err != nil {
+		logger.Debug("Could not fetch robots.txt", "origin", origin, "error", err)
+		return robotsRules{}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return robotsRules{unavailable: true}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return robotsRules{}
+	}
+	return parseRobots(io.LimitReader(resp.Body, min(maxRobotsSize, c.web.maxBodySize)), c.web.userAgent)
+}
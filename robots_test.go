@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := `# Rules for everyone
+User-agent: *
+Disallow: /private/
+Allow: /private/feed.xml
+Disallow: /*.json$
+Crawl-delay: 5
+
+User-agent: OtherBot
+User-agent: Curator
+Disallow: /blocked # only for us
+Crawl-delay: 0.5
+`
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		expected  bool
+	}{
+		{"Wildcard group allows other paths", "reader/1.0", "/feed.xml", true},
+		{"Wildcard group disallows", "reader/1.0", "/private/other.xml", false},
+		{"Longest match wins", "reader/1.0", "/private/feed.xml", true},
+		{"Pattern with end anchor", "reader/1.0", "/feed.json", false},
+		{"End anchor does not match longer paths", "reader/1.0", "/feed.json?page=2", true},
+		{"Specific group replaces the wildcard group", defaultUserAgent, "/private/other.xml", true},
+		{"Specific group disallows", defaultUserAgent, "/blocked/feed.xml", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(robots), tt.userAgent)
+			u, _ := url.Parse("https://example.com" + tt.path)
+			if allowed := rules.allowed(u); allowed != tt.expected {
+				t.Errorf("Expected allowed %t for %s, got %t", tt.expected, tt.path, allowed)
+			}
+		})
+	}
+
+	t.Run("Crawl delay", func(t *testing.T) {
+		if delay := parseRobots(strings.NewReader(robots), "reader/1.0").crawlDelay; delay != 5*time.Second {
+			t.Errorf("Expected crawl delay 5s, got %v", delay)
+		}
+		if delay := parseRobots(strings.NewReader(robots), defaultUserAgent).crawlDelay; delay != 500*time.Millisecond {
+			t.Errorf("Expected crawl delay 500ms, got %v", delay)
+		}
+	})
+
+	t.Run("Empty robots.txt allows everything", func(t *testing.T) {
+		u, _ := url.Parse("https://example.com/private/")
+		if !parseRobots(strings.NewReader(""), defaultUserAgent).allowed(u) {
+			t.Error("Expected everything to be allowed")
+		}
+	})
+}
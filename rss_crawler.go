@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
-	"encoding/xml"
-	"io"
-	"net/http"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,53 +21,105 @@ type RSSFeed struct {
 }
 
 type RSSChannel struct {
-	Title       string        `xml:"title"`
-	Link        string        `xml:"link"`
-	Description string        `xml:"description"`
-	Language    string        `xml:"language"`
+	Title string `xml:"title"`
+	// AtomLinks come first, otherwise <atom:link> would be decoded as the channel link.
+	// Other feed formats map their hub and self links into them
+	AtomLinks   []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	Language    string     `xml:"language"`
+	TTL         string     `xml:"ttl"`
+	SkipHours   []string   `xml:"skipHours>hour"`
+	SkipDays    []string   `xml:"skipDays>day"`
+	// The namespaced field comes first, otherwise <itunes:image> would be decoded as the channel image
+	ITunesImage ITunesImage   `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+	Image       RSSImage      `xml:"image"`
 	Item        []RSSFeedItem `xml:"item"`
 }
 
 type RSSFeedItem struct {
+	// GUID identifies the item within its feed. Atom and JSON feeds map their entry IDs into it
+	GUID        string `xml:"guid"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	Author      string `xml:"author"`
+	// Creators and Categories can be repeated. Other feed formats map their authors and tags into them
+	Creators   []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories []string `xml:"category"`
+	// Content holds the full article, which many feeds provide through content:encoded
+	Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	// Media files, like podcast episodes, come from enclosures and the Media RSS and iTunes namespaces
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+	MediaContent   []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups    []MediaGroup   `xml:"http://search.yahoo.com/mrss/ group"`
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
 }
 
-func urlToFeed(url string) (RSSFeed, error) {
-	httpClient := http.Client{
-		Timeout: 10 * time.Second,
+// identity returns the value used to recognise an item across fetches: its GUID, falling back to its link.
+// An empty identity means the item cannot be told apart from the others in the feed.
+func (item RSSFeedItem) identity() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
 	}
+	return strings.TrimSpace(item.Link)
+}
 
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return RSSFeed{}, err
-	}
-	defer resp.Body.Close()
+// crawler periodically fetches the feeds stored in the database and creates posts for their new items.
+type crawler struct {
+	conn    *sql.DB // used to run transactions
+	db      *database.Queries
+	fetcher Fetcher
+	web     *webClient    // used for the requests made around feeds, like favicon lookups
+	websub  *websubClient // nil when WebSub is disabled
+	// maxFailures is the number of consecutive failed fetches after which a feed is disabled
+	maxFailures int32
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return RSSFeed{}, err
-	}
+	// inFlight holds the feeds being fetched, so manual refreshes and the ticker do not fetch a feed twice at once
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]bool
+}
 
-	rssFeed := RSSFeed{}
-	err = xml.Unmarshal(data, &rssFeed)
-	if err != nil {
-		return RSSFeed{}, err
-	}
+var errFetchInProgress = errors.New("feed is already being fetched")
 
-	return rssFeed, nil
+// maxFeedFetches is the number of fetch attempts kept in the history of each feed.
+const maxFeedFetches = 100
+
+// fetchSummary reports the outcome of a feed fetch.
+type fetchSummary struct {
+	NotModified  bool
+	NewPosts     int
+	UpdatedPosts int
+	// MergedInto is the feed the fetched feed was merged into after a permanent redirect, uuid.Nil otherwise
+	MergedInto uuid.UUID
+	// Errors holds the problems found while storing items, which do not fail the fetch itself
+	Errors []string
+}
+
+func newCrawler(conn *sql.DB, fetcher Fetcher, web *webClient, websub *websubClient, maxFailures int32) *crawler {
+	return &crawler{
+		conn:        conn,
+		db:          database.New(conn),
+		fetcher:     fetcher,
+		web:         web,
+		websub:      websub,
+		maxFailures: maxFailures,
+		inFlight:    map[uuid.UUID]bool{},
+	}
 }
 
 // startFeedScrapping initiates the scraping operation with the specified parameters.
 // It calls scrapeFeed to fetch feeds from the database with the given concurrency and time interval between requests.
-func startFeedScrapping(db *database.Queries, concurrency int, timeBetweenRequest time.Duration) {
+func (c *crawler) startFeedScrapping(concurrency int, timeBetweenRequest time.Duration) {
 	logger.Info("Starting scrape operation", "concurrency", concurrency, "interval", timeBetweenRequest.String())
 
 	ticker := time.NewTicker(timeBetweenRequest)
 	for range ticker.C {
-		feeds, err := db.GetNextFeedsToFetch(context.Background(), int32(concurrency))
+		feeds, err := c.db.GetNextFeedsToFetch(context.Background(), database.GetNextFeedsToFetchParams{
+			Now:      time.Now().UTC(),
+			MaxFeeds: int32(concurrency),
+		})
 		if err != nil {
 			logger.Error("Error fetching feeds", "error", err)
 			continue
@@ -73,71 +128,522 @@ func startFeedScrapping(db *database.Queries, concurrency int, timeBetweenReques
 		for _, feed := range feeds {
 			wg.Add(1)
 
-			go scrapeFeed(db, wg, feed)
+			go func(feed database.Feed) {
+				defer wg.Done()
+				c.scrapeFeed(context.Background(), feed)
+			}(feed)
 		}
 		wg.Wait()
 	}
 }
 
-// scrapeFeed fetches and processes the feed data.
-// It fetches the feed data, marks the feed as fetched in the database and logs the new posts found.
-func scrapeFeed(db *database.Queries, wg *sync.WaitGroup, feed database.Feed) {
-	defer wg.Done()
+// claim marks a feed as being fetched, returning false when it already is.
+func (c *crawler) claim(feedID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight[feedID] {
+		return false
+	}
+	c.inFlight[feedID] = true
+	return true
+}
 
-	_, err := db.MarkFeedAsFetched(context.Background(), feed.ID)
+func (c *crawler) release(feedID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inFlight, feedID)
+}
+
+// scrapeFeed claims the feed and fetches it, unless it is already being fetched.
+func (c *crawler) scrapeFeed(ctx context.Context, feed database.Feed) (fetchSummary, error) {
+	if !c.claim(feed.ID) {
+		return fetchSummary{}, errFetchInProgress
+	}
+	defer c.release(feed.ID)
+	return c.fetchFeed(ctx, feed)
+}
+
+// fetchFeed fetches and processes the feed data, for a feed the caller already claimed.
+// It fetches the feed data, marks the feed as fetched in the database and returns a summary of the posts found.
+// ctx only bounds the feed request: once the feed is fetched, its posts are stored even if ctx is cancelled.
+// The returned error is only set when the feed itself could not be fetched.
+func (c *crawler) fetchFeed(ctx context.Context, feed database.Feed) (fetchSummary, error) {
+	_, err := c.db.MarkFeedAsFetched(context.Background(), feed.ID)
 	if err != nil {
 		logger.Error("Error marking feed as fetched", "feedID", feed.ID, "feedName", feed.Name)
-		return
+		return fetchSummary{}, err
 	}
-	rssFeed, err := urlToFeed(feed.Url)
-	if err != nil {
+	fetchedAt := time.Now().UTC()
+	resp, err := c.fetcher.Fetch(ctx, feed.Url, cacheValidators{ETag: feed.Etag.String, LastModified: feed.LastModified.String})
+	var busy *hostBusyError
+	switch {
+	case errors.As(err, &busy):
+		// The feed was not requested, so it is only fetched again once its host is free
+		logger.Info("Feed host busy, rescheduling fetch", "feedID", feed.ID, "host", busy.Host, "retryAt", busy.RetryAt)
+		c.deferFetch(feed, busy.RetryAt)
+		return fetchSummary{}, err
+	case errors.Is(err, errDisallowedByRobots):
+		// Skipping a feed out of politeness is not a failure of the feed, so it does not count towards disabling it.
+		// The feed is checked again once the robots.txt rules are fetched again
+		logger.Info("Feed disallowed by robots.txt, skipping", "feedID", feed.ID, "url", feed.Url)
+		c.deferFetch(feed, fetchedAt.Add(robotsTTL))
+		c.recordFetch(feed, fetchedAt, resp, fetchSummary{}, err)
+		return fetchSummary{}, err
+	case err != nil && ctx.Err() != nil:
+		// The caller gave up on the fetch, which says nothing about the feed
+		logger.Info("Feed fetch cancelled", "feedID", feed.ID, "error", err)
+		return fetchSummary{}, err
+	case err != nil:
 		logger.Error("Error fetching feed data", "feedID", feed.ID, "error", err)
-		return
+		c.markFeedFetchFailed(feed, err)
+		c.recordFetch(feed, fetchedAt, resp, fetchSummary{}, err)
+		return fetchSummary{}, err
+	}
+	err = c.db.MarkFeedFetchSucceeded(context.Background(), database.MarkFeedFetchSucceededParams{
+		ID:            feed.ID,
+		LastSuccessAt: sql.NullTime{Time: fetchedAt, Valid: true},
+	})
+	if err != nil {
+		logger.Error("Error marking feed fetch as successful", "feedID", feed.ID, "error", err)
+	}
+	if resp.PermanentURL != "" && resp.PermanentURL != feed.Url {
+		target, merged, err := c.moveFeed(feed, resp.PermanentURL)
+		if err != nil {
+			logger.Error("Error updating permanently redirected feed URL", "feedID", feed.ID, "url", resp.PermanentURL, "error", err)
+		}
+		// The merged feed no longer exists, along with its fetch history.
+		// Its new items will be picked up when the existing feed is fetched
+		if merged {
+			return fetchSummary{MergedInto: target}, nil
+		}
+	}
+	schedule := fetchSchedule{
+		PreviousInterval: time.Duration(feed.FetchIntervalSeconds) * time.Second,
+		CacheLifetime:    resp.CacheLifetime,
+	}
+	if resp.NotModified {
+		logger.Info("Feed not modified since last fetch", "feedID", feed.ID)
+		c.renewWebSubSubscription(feed)
+		c.scheduleNextFetch(feed, schedule)
+		summary := fetchSummary{NotModified: true}
+		c.recordFetch(feed, fetchedAt, resp, summary, nil)
+		return summary, nil
 	}
 
+	err = c.db.UpdateFeedCacheValidators(context.Background(), database.UpdateFeedCacheValidatorsParams{
+		ID:           feed.ID,
+		Etag:         sql.NullString{String: resp.Validators.ETag, Valid: resp.Validators.ETag != ""},
+		LastModified: sql.NullString{String: resp.Validators.LastModified, Valid: resp.Validators.LastModified != ""},
+	})
+	if err != nil {
+		logger.Error("Error storing feed cache validators", "feedID", feed.ID, "error", err)
+	}
+
+	rssFeed := resp.Feed
+	c.updateFeedMetadata(feed, rssFeed.Channel)
+	c.updateWebSubSubscription(feed, rssFeed.Channel)
+	schedule.TTL = parseTTL(rssFeed.Channel.TTL)
+	schedule.SkipHours = parseSkipHours(rssFeed.Channel.SkipHours)
+	schedule.SkipDays = parseSkipDays(rssFeed.Channel.SkipDays)
 	for _, item := range rssFeed.Channel.Item {
-		// Check in the database if the post already exists by its unique URL
-		post, err := db.FindPostByURL(context.Background(), item.Link)
-		if err != nil {
-			// If there was an error, just log it and skip this post
-			logger.Error("Error checking if post already exists", "error", err)
+		if pubDate, err := parseDate(item.PubDate); err == nil {
+			schedule.PubDates = append(schedule.PubDates, pubDate)
+		}
+	}
+
+	summary := c.ingestItems(feed, rssFeed.Channel.Item, fetchedAt)
+	schedule.NewPosts = summary.NewPosts
+	logger.Info("Feed scrapping complete", "feedID", feed.ID, "numPosts", len(rssFeed.Channel.Item), "newPosts", summary.NewPosts, "updatedPosts", summary.UpdatedPosts)
+	c.scheduleNextFetch(feed, schedule)
+	c.recordFetch(feed, fetchedAt, resp, summary, nil)
+	return summary, nil
+}
+
+// ingestItems creates posts for the new items of a feed and applies upstream edits to the stored ones.
+// Dates that cannot be parsed are estimated with fetchedAt.
+func (c *crawler) ingestItems(feed database.Feed, items []RSSFeedItem, fetchedAt time.Time) fetchSummary {
+	summary := fetchSummary{Errors: []string{}}
+	// Feed URLs are validated when the feed is created, so a parse error only leaves relative enclosures unresolved
+	feedURL, _ := url.Parse(feed.Url)
+	for _, item := range items {
+		guid := item.identity()
+		if guid == "" {
+			logger.Warn("Skipping item without guid or link", "feedID", feed.ID, "title", item.Title)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("Skipped item %q without guid or link", item.Title))
 			continue
 		}
-		// If post tile is not empty and the creation data is not zero, we assume the post is already on the database
-		if post.Title != "" && !post.CreatedAt.IsZero() {
-			logger.Debug("Post already present, skipping", "postID", post.ID)
+		body := newPostBody(item)
+
+		// Posts are unique per feed, matched by guid or, for posts stored before the feed had guids, by link
+		post, err := c.db.FindFeedPost(context.Background(), database.FindFeedPostParams{
+			FeedID: feed.ID,
+			Guid:   guid,
+			Url:    sql.NullString{String: item.Link, Valid: item.Link != ""},
+		})
+		if err == nil {
+			// Posts matched by link still need their guid updated, even when the item did not change
+			if post.Guid == guid && post.ContentHash.Valid && post.ContentHash.String == body.Hash {
+				logger.Debug("Post already present, skipping", "postID", post.ID)
+				continue
+			}
+			if err := c.updatePost(post, guid, item, body); err != nil {
+				logger.Error("Could not update post.", "postID", post.ID, "error", err)
+				summary.Errors = append(summary.Errors, fmt.Sprintf("Could not update post %s: %v", guid, err))
+				continue
+			}
+			summary.UpdatedPosts++
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			// If there was an error, just log it and skip this post
+			logger.Error("Error checking if post already exists", "error", err)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("Could not check post %s: %v", guid, err))
 			continue
 		}
 
-		// Since description can be null, we check for that before database operations start
-		desc := sql.NullString{}
-		if item.Description != "" {
-			desc.String = item.Description
-			desc.Valid = true
+		// Items with a missing or unknown date are kept, using the fetch time as an estimate
+		pubDate, estimated := parsePublishedDate(item.PubDate, fetchedAt)
+		if estimated {
+			logger.Warn("Could not parse published date, using fetch time", "pubDate", item.PubDate, "url", item.Link)
 		}
 
-		// TODO: Add support for more data formats
-		pubDate, err := time.Parse(time.RFC1123Z, item.PubDate)
+		post, err = c.db.CreatePost(context.Background(), database.CreatePostParams{
+			ID:                   uuid.New(),
+			CreatedAt:            time.Now().UTC(),
+			UpdatedAt:            time.Now().UTC(),
+			Title:                item.Title,
+			Url:                  item.Link,
+			Description:          body.Description,
+			PublishedAt:          pubDate,
+			FeedID:               feed.ID,
+			PublishedAtEstimated: estimated,
+			Content:              body.Content,
+			Excerpt:              body.Excerpt,
+			Guid:                 guid,
+			ContentHash:          sql.NullString{String: body.Hash, Valid: true},
+		})
+
 		if err != nil {
-			logger.Error("Could not parse published date", "error", err, "pubDate", item.PubDate)
+			logger.Error("Could not create post.", "feedID", feed.ID, "url", item.Link, "error", err)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("Could not create post %s: %v", guid, err))
 			continue
 		}
+		c.createEnclosures(post, item.mediaEnclosures(feedURL))
+		c.addAuthorsAndCategories(post, item.postAuthors(), item.postCategories())
+		summary.NewPosts++
+	}
+	return summary
+}
 
-		_, err = db.CreatePost(context.Background(), database.CreatePostParams{
+// updateFeedMetadata stores the channel title, site link, description, language and image of a feed.
+// When the channel has no image, the site favicon is looked up, unless an image was already resolved before
+// or the last lookup is too recent.
+func (c *crawler) updateFeedMetadata(feed database.Feed, channel RSSChannel) {
+	feedURL, err := url.Parse(feed.Url)
+	if err != nil {
+		logger.Error("Could not parse feed URL", "feedID", feed.ID, "url", feed.Url, "error", err)
+		return
+	}
+	siteURL := resolveWebURL(feedURL, channel.Link)
+	imageURL := resolveWebURL(feedURL, channel.imageURL())
+	var faviconCheckedAt sql.NullTime
+	if imageURL == "" && !feed.ImageUrl.Valid && siteURL != "" && faviconLookupDue(feed, time.Now().UTC()) {
+		icon, answered := findFavicon(context.Background(), c.web, siteURL)
+		imageURL = icon
+		// Lookups the site could not answer, like those refused because the host was busy, are tried again on the next fetch
+		if answered {
+			faviconCheckedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+	}
+
+	title := strings.TrimSpace(channel.Title)
+	description := strings.TrimSpace(channel.Description)
+	language := strings.TrimSpace(channel.Language)
+	err = c.db.UpdateFeedMetadata(context.Background(), database.UpdateFeedMetadataParams{
+		ID:          feed.ID,
+		Title:       sql.NullString{String: title, Valid: title != ""},
+		SiteUrl:     sql.NullString{String: siteURL, Valid: siteURL != ""},
+		Description: sql.NullString{String: description, Valid: description != ""},
+		Language:    sql.NullString{String: language, Valid: language != ""},
+		ImageUrl:    sql.NullString{String: imageURL, Valid: imageURL != ""},
+		// Lookups that found nothing are recorded too, so sites without an icon are not requested on every fetch
+		FaviconCheckedAt: faviconCheckedAt,
+	})
+	if err != nil {
+		logger.Error("Error storing feed metadata", "feedID", feed.ID, "error", err)
+	}
+}
+
+// postBody holds the sanitised item fields stored on a post, along with the hash used to detect upstream edits.
+type postBody struct {
+	Description sql.NullString
+	Content     sql.NullString
+	Excerpt     sql.NullString
+	Hash        string
+}
+
+// newPostBody sanitises the item description and content, deriving the excerpt and content hash from the results.
+func newPostBody(item RSSFeedItem) postBody {
+	// Feed bodies are served to clients, so they are sanitised before being stored
+	var baseURL *url.URL
+	if itemURL, err := url.Parse(item.Link); err == nil && itemURL.IsAbs() {
+		baseURL = itemURL
+	}
+	description := sanitizeHTML(item.Description, baseURL)
+	content := sanitizeHTML(item.Content, baseURL)
+
+	// The excerpt is taken from the full article when available, as descriptions are often truncated already
+	excerptSource := content
+	if excerptSource == "" {
+		excerptSource = description
+	}
+	excerpt := htmlExcerpt(excerptSource, excerptLength)
+
+	// Fields are separated by a NUL byte, so moving text from one field into the next changes the hash
+	hash := sha256.Sum256([]byte(strings.Join([]string{item.Title, item.Link, description, content}, "\x00")))
+
+	// Since description, content and excerpt can be null, empty values are stored as null
+	return postBody{
+		Description: sql.NullString{String: description, Valid: description != ""},
+		Content:     sql.NullString{String: content, Valid: content != ""},
+		Excerpt:     sql.NullString{String: excerpt, Valid: excerpt != ""},
+		Hash:        hex.EncodeToString(hash[:]),
+	}
+}
+
+// updatePost applies an upstream edit to a stored post, keeping its previous version as a revision.
+// Posts stored before content hashes were introduced have nothing to compare against,
+// so they are updated without recording a revision. Posts matched by link also get the guid of the item.
+func (c *crawler) updatePost(post database.Post, guid string, item RSSFeedItem, body postBody) error {
+	ctx := context.Background()
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	if post.ContentHash.Valid && post.ContentHash.String != body.Hash {
+		err = qtx.CreatePostRevision(ctx, database.CreatePostRevisionParams{
 			ID:          uuid.New(),
 			CreatedAt:   time.Now().UTC(),
-			UpdatedAt:   time.Now().UTC(),
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: desc,
-			PublishedAt: pubDate,
-			FeedID:      feed.ID,
+			PostID:      post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			Content:     post.Content,
 		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = qtx.UpdatePostContent(ctx, database.UpdatePostContentParams{
+		Title:       item.Title,
+		Url:         item.Link,
+		Guid:        guid,
+		Description: body.Description,
+		Content:     body.Content,
+		Excerpt:     body.Excerpt,
+		ContentHash: sql.NullString{String: body.Hash, Valid: true},
+		ID:          post.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if post.ContentHash.Valid && post.ContentHash.String != body.Hash {
+		logger.Info("Post updated after upstream edit", "postID", post.ID)
+	}
+	return nil
+}
 
+// createEnclosures stores the media files of a new post. Failures are logged, since the post itself was already stored.
+func (c *crawler) createEnclosures(post database.Post, enclosures []mediaEnclosure) {
+	for _, enclosure := range enclosures {
+		_, err := c.db.CreateEnclosure(context.Background(), database.CreateEnclosureParams{
+			ID:              uuid.New(),
+			CreatedAt:       time.Now().UTC(),
+			UpdatedAt:       time.Now().UTC(),
+			PostID:          post.ID,
+			Url:             enclosure.URL,
+			MimeType:        sql.NullString{String: enclosure.MimeType, Valid: enclosure.MimeType != ""},
+			Length:          sql.NullInt64{Int64: enclosure.Length, Valid: enclosure.Length > 0},
+			DurationSeconds: sql.NullInt32{Int32: enclosure.DurationSeconds, Valid: enclosure.DurationSeconds > 0},
+		})
 		if err != nil {
-			logger.Error("Could not create post.", "feedID", feed.ID, "url", item.Link, "error", err)
-			continue
+			logger.Error("Could not create enclosure.", "postID", post.ID, "url", enclosure.URL, "error", err)
+		}
+	}
+}
+
+// addAuthorsAndCategories links a new post to its authors and categories, creating the ones seen for the first time.
+// Failures are logged, since the post itself was already stored.
+func (c *crawler) addAuthorsAndCategories(post database.Post, authors, categories []string) {
+	ctx := context.Background()
+	for _, name := range authors {
+		author, err := c.db.UpsertAuthor(ctx, database.UpsertAuthorParams{ID: uuid.New(), CreatedAt: time.Now().UTC(), Name: name})
+		if err == nil {
+			err = c.db.AddPostAuthor(ctx, database.AddPostAuthorParams{PostID: post.ID, AuthorID: author.ID})
+		}
+		if err != nil {
+			logger.Error("Could not add post author.", "postID", post.ID, "author", name, "error", err)
 		}
 	}
-	logger.Info("Feed scrapping complete", "feedID", feed.ID, "numPosts", len(rssFeed.Channel.Item))
+	for _, name := range categories {
+		category, err := c.db.UpsertCategory(ctx, database.UpsertCategoryParams{ID: uuid.New(), CreatedAt: time.Now().UTC(), Name: name})
+		if err == nil {
+			err = c.db.AddPostCategory(ctx, database.AddPostCategoryParams{PostID: post.ID, CategoryID: category.ID})
+		}
+		if err != nil {
+			logger.Error("Could not add post category.", "postID", post.ID, "category", name, "error", err)
+		}
+	}
+}
+
+// moveFeed updates the URL of a feed that was permanently redirected.
+// When another feed already uses the new URL, follows and posts are merged into it and the redirected feed is deleted,
+// in which case merged is true and target is the ID of the feed it was merged into.
+func (c *crawler) moveFeed(feed database.Feed, newURL string) (target uuid.UUID, merged bool, err error) {
+	ctx := context.Background()
+	existing, err := c.db.GetFeedByURL(ctx, newURL)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		err = c.db.UpdateFeedURL(ctx, database.UpdateFeedURLParams{ID: feed.ID, Url: newURL})
+		if err != nil {
+			return uuid.Nil, false, err
+		}
+		logger.Info("Feed URL updated after permanent redirect", "feedID", feed.ID, "oldURL", feed.Url, "newURL", newURL)
+		return uuid.Nil, false, nil
+	}
+
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	// Users already following the existing feed keep their follow, the duplicated ones go away with the deleted feed
+	err = qtx.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{TargetFeedID: existing.ID, SourceFeedID: feed.ID})
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	err = qtx.DeleteDuplicatePosts(ctx, database.DeleteDuplicatePostsParams{SourceFeedID: feed.ID, TargetFeedID: existing.ID})
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	err = qtx.MovePosts(ctx, database.MovePostsParams{TargetFeedID: existing.ID, SourceFeedID: feed.ID})
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	err = qtx.DeleteFeed(ctx, feed.ID)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	logger.Info("Feed merged into existing feed after permanent redirect", "feedID", feed.ID, "targetFeedID", existing.ID, "url", newURL)
+	return existing.ID, true, nil
+}
+
+// markFeedFetchFailed records the fetch error on the feed and backs off exponentially before the next attempt.
+// Once the feed reaches the maximum number of consecutive failures it is disabled, so it stops taking crawler slots.
+func (c *crawler) markFeedFetchFailed(feed database.Feed, fetchErr error) {
+	failures := feed.ConsecutiveFailures + 1
+	backoff := failureBackoff(failures)
+	err := c.db.MarkFeedFetchFailed(context.Background(), database.MarkFeedFetchFailedParams{
+		ID:                  feed.ID,
+		ConsecutiveFailures: failures,
+		LastError:           sql.NullString{String: fetchErr.Error(), Valid: true},
+		NextFetchAt:         sql.NullTime{Time: time.Now().UTC().Add(backoff), Valid: true},
+	})
+	if err != nil {
+		logger.Error("Error recording feed fetch failure", "feedID", feed.ID, "error", err)
+		return
+	}
+	logger.Warn("Feed fetch failed, backing off", "feedID", feed.ID, "failures", failures, "backoff", backoff.String())
+
+	if c.maxFailures > 0 && failures >= c.maxFailures {
+		err = c.db.DisableFeed(context.Background(), database.DisableFeedParams{
+			ID:         feed.ID,
+			DisabledAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		})
+		if err != nil {
+			logger.Error("Error disabling failing feed", "feedID", feed.ID, "error", err)
+			return
+		}
+		logger.Warn("Feed disabled after too many consecutive failures", "feedID", feed.ID, "failures", failures)
+	}
+}
+
+// recordFetch adds a fetch attempt to the history of the feed, dropping the oldest entries past maxFeedFetches.
+// Failures are logged, since the fetch itself already happened.
+func (c *crawler) recordFetch(feed database.Feed, startedAt time.Time, resp feedResponse, summary fetchSummary, fetchErr error) {
+	// Problems storing items do not fail the fetch, but are recorded as well since they explain missing posts
+	var errorMessage string
+	if fetchErr != nil {
+		errorMessage = fetchErr.Error()
+	} else {
+		errorMessage = strings.Join(summary.Errors, "; ")
+	}
+
+	ctx := context.Background()
+	err := c.db.CreateFeedFetch(ctx, database.CreateFeedFetchParams{
+		ID:           uuid.New(),
+		FeedID:       feed.ID,
+		StartedAt:    startedAt,
+		FinishedAt:   time.Now().UTC(),
+		StatusCode:   sql.NullInt32{Int32: int32(resp.StatusCode), Valid: resp.StatusCode != 0},
+		BodyBytes:    resp.BodySize,
+		ItemsSeen:    int32(len(resp.Feed.Channel.Item)),
+		PostsCreated: int32(summary.NewPosts),
+		PostsUpdated: int32(summary.UpdatedPosts),
+		Error:        sql.NullString{String: errorMessage, Valid: errorMessage != ""},
+	})
+	if err != nil {
+		logger.Error("Error recording feed fetch", "feedID", feed.ID, "error", err)
+		return
+	}
+	err = c.db.PruneFeedFetches(ctx, database.PruneFeedFetchesParams{FeedID: feed.ID, MaxFetches: maxFeedFetches})
+	if err != nil {
+		logger.Error("Error pruning feed fetch history", "feedID", feed.ID, "error", err)
+	}
+}
+
+// deferFetch postpones the next fetch of a feed that was skipped, keeping its fetch interval.
+func (c *crawler) deferFetch(feed database.Feed, nextFetchAt time.Time) {
+	err := c.db.ScheduleNextFetch(context.Background(), database.ScheduleNextFetchParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: nextFetchAt, Valid: true},
+		FetchIntervalSeconds: feed.FetchIntervalSeconds,
+	})
+	if err != nil {
+		logger.Error("Error postponing feed fetch", "feedID", feed.ID, "error", err)
+	}
+}
+
+// scheduleNextFetch stores when the feed should be fetched again, based on the outcome of the current fetch.
+func (c *crawler) scheduleNextFetch(feed database.Feed, schedule fetchSchedule) {
+	nextFetchAt, interval := schedule.nextFetch(time.Now().UTC())
+	// Pushed feeds are only polled again to renew their subscription, falling back to polling once the lease expires
+	if renewAt, ok := c.websubRenewal(feed.ID); ok && renewAt.After(nextFetchAt) {
+		nextFetchAt = renewAt
+	}
+	err := c.db.ScheduleNextFetch(context.Background(), database.ScheduleNextFetchParams{
+		ID:                   feed.ID,
+		NextFetchAt:          sql.NullTime{Time: nextFetchAt, Valid: true},
+		FetchIntervalSeconds: int32(interval.Seconds()),
+	})
+	if err != nil {
+		logger.Error("Error scheduling next feed fetch", "feedID", feed.ID, "error", err)
+		return
+	}
+	logger.Debug("Next feed fetch scheduled", "feedID", feed.ID, "nextFetchAt", nextFetchAt, "interval", interval.String())
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deadpyxel/curator/internal/database"
+	"github.com/google/uuid"
+)
+
+// newTestDB connects to the database in TEST_CONN_STRING and applies the migrations to a schema of its own,
+// which is dropped once the test is done. Tests using it are skipped when no test database is configured.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	connString := os.Getenv("TEST_CONN_STRING")
+	if connString == "" {
+		t.Skip("TEST_CONN_STRING is not set")
+	}
+
+	admin, err := sql.Open("postgres", connString)
+	if err != nil {
+		t.Fatalf("Could not connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("Could not create the test schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	// Unknown connection parameters are sent as run-time parameters, so every connection uses the test schema
+	separator := " "
+	if strings.Contains(connString, "://") {
+		separator = "&"
+		if !strings.Contains(connString, "?") {
+			separator = "?"
+		}
+	}
+	conn, err := sql.Open("postgres", connString+separator+"search_path="+schema)
+	if err != nil {
+		t.Fatalf("Could not connect to the test database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrations, err := filepath.Glob("sql/schema/*.sql")
+	if err != nil {
+		t.Fatalf("Could not list migrations: %v", err)
+	}
+	for _, migration := range migrations {
+		contents, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatalf("Could not read migration %s: %v", migration, err)
+		}
+		up, _, _ := strings.Cut(string(contents), "-- +goose Down")
+		if _, err := conn.Exec(up); err != nil {
+			t.Fatalf("Could not apply migration %s: %v", migration, err)
+		}
+	}
+	return conn
+}
+
+// createTestFeed stores a user and a feed owned by them.
+func createTestFeed(t *testing.T, db *database.Queries) database.Feed {
+	t.Helper()
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Name:      "Test user",
+	})
+	if err != nil {
+		t.Fatalf("Could not create user: %v", err)
+	}
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Name:      "Test feed",
+		Url:       fmt.Sprintf("https://example.com/%s.xml", uuid.NewString()),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("Could not create feed: %v", err)
+	}
+	return feed
+}
+
+// feedPostGUIDs returns the guid and title of the posts of a feed, keyed by guid.
+func feedPostGUIDs(t *testing.T, conn *sql.DB, feedID uuid.UUID) map[string]string {
+	t.Helper()
+	rows, err := conn.Query("SELECT guid, title FROM posts WHERE feed_id = $1", feedID)
+	if err != nil {
+		t.Fatalf("Could not list posts: %v", err)
+	}
+	defer rows.Close()
+	posts := map[string]string{}
+	for rows.Next() {
+		var guid, title string
+		if err := rows.Scan(&guid, &title); err != nil {
+			t.Fatalf("Could not read post: %v", err)
+		}
+		posts[guid] = title
+	}
+	return posts
+}
+
+func TestItemIdentity(t *testing.T) {
+	tests := []struct {
+		name     string
+		item     RSSFeedItem
+		expected string
+	}{
+		{"Uses the guid", RSSFeedItem{GUID: " post-1 ", Link: "https://example.com/1"}, "post-1"},
+		{"Falls back to the link", RSSFeedItem{Link: "https://example.com/1"}, "https://example.com/1"},
+		{"Empty without guid and link", RSSFeedItem{Title: "Untitled"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.item.identity(); result != tt.expected {
+				t.Errorf("Expected identity %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewPostBodyHash(t *testing.T) {
+	item := RSSFeedItem{
+		Title:       "Post",
+		Link:        "https://example.com/post",
+		Description: `<p>Summary</p>`,
+		Content:     `<p>Body</p>`,
+	}
+	original := newPostBody(item).Hash
+
+	if hash := newPostBody(item).Hash; hash != original {
+		t.Errorf("Expected the same item to hash to %q, got %q", original, hash)
+	}
+	// Markup removed by the sanitiser is not an edit readers can see
+	tracked := item
+	tracked.Content = `<p>Body</p><script>track()</script>`
+	if hash := newPostBody(tracked).Hash; hash != original {
+		t.Errorf("Expected sanitised markup changes to keep hash %q, got %q", original, hash)
+	}
+
+	edits := map[string]func(item *RSSFeedItem){
+		"Title":       func(item *RSSFeedItem) { item.Title = "Post (corrected)" },
+		"Link":        func(item *RSSFeedItem) { item.Link = "https://example.com/post-corrected" },
+		"Description": func(item *RSSFeedItem) { item.Description = `<p>New summary</p>` },
+		"Content":     func(item *RSSFeedItem) { item.Content = `<p>New body</p>` },
+		"Moved text":  func(item *RSSFeedItem) { item.Description, item.Content = `<p>Summary</p><p>Body</p>`, "" },
+	}
+	for name, edit := range edits {
+		t.Run(name, func(t *testing.T) {
+			edited := item
+			edit(&edited)
+			if hash := newPostBody(edited).Hash; hash == original {
+				t.Errorf("Expected hash to change after editing the %s", name)
+			}
+		})
+	}
+}
+
+func TestCrawlerClaim(t *testing.T) {
+	c := newCrawler(nil, &fakeFetcher{}, nil, nil, 0)
+	feedID := uuid.New()
+
+	if !c.claim(feedID) {
+		t.Fatal("Expected the first claim to succeed")
+	}
+	if c.claim(feedID) {
+		t.Error("Expected a feed being fetched not to be claimed again")
+	}
+	if !c.claim(uuid.New()) {
+		t.Error("Expected other feeds to be claimed")
+	}
+	c.release(feedID)
+	if !c.claim(feedID) {
+		t.Error("Expected a released feed to be claimed again")
+	}
+}
+
+func TestIngestItemsSharedLink(t *testing.T) {
+	conn := newTestDB(t)
+	c := newCrawler(conn, &fakeFetcher{}, nil, nil, 0)
+	feed := createTestFeed(t, c.db)
+
+	// Some feeds point every item to the same page, so only the guid tells them apart
+	items := []RSSFeedItem{
+		{GUID: "episode-1", Title: "Episode 1", Link: "https://example.com/podcast"},
+		{GUID: "episode-2", Title: "Episode 2", Link: "https://example.com/podcast"},
+	}
+	for i := 0; i < 2; i++ {
+		summary := c.ingestItems(feed, items, time.Now().UTC())
+		if len(summary.Errors) != 0 {
+			t.Fatalf("Expected no errors, got %v", summary.Errors)
+		}
+		if i == 1 && (summary.NewPosts != 0 || summary.UpdatedPosts != 0) {
+			t.Errorf("Expected unchanged items to be skipped, got %+v", summary)
+		}
+	}
+
+	expected := map[string]string{"episode-1": "Episode 1", "episode-2": "Episode 2"}
+	posts := feedPostGUIDs(t, conn, feed.ID)
+	if len(posts) != len(expected) {
+		t.Fatalf("Expected posts %v, got %v", expected, posts)
+	}
+	for guid, title := range expected {
+		if posts[guid] != title {
+			t.Errorf("Expected post %q to be titled %q, got %q", guid, title, posts[guid])
+		}
+	}
+}
+
+func TestIngestItemsLegacyPost(t *testing.T) {
+	conn := newTestDB(t)
+	c := newCrawler(conn, &fakeFetcher{}, nil, nil, 0)
+	feed := createTestFeed(t, c.db)
+
+	// Posts stored before guids were introduced use their link as guid
+	legacy := RSSFeedItem{Title: "Post", Link: "https://example.com/post"}
+	c.ingestItems(feed, []RSSFeedItem{legacy}, time.Now().UTC())
+
+	item := legacy
+	item.GUID = "post-1"
+	summary := c.ingestItems(feed, []RSSFeedItem{item}, time.Now().UTC())
+	if summary.NewPosts != 0 {
+		t.Errorf("Expected the legacy post to be matched by link, got %d new posts", summary.NewPosts)
+	}
+	posts := feedPostGUIDs(t, conn, feed.ID)
+	if len(posts) != 1 || posts["post-1"] != "Post" {
+		t.Errorf("Expected the legacy post to take the item guid, got %v", posts)
+	}
+}
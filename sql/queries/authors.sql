@@ -0,0 +1,17 @@
+-- name: UpsertAuthor :one
+-- Names are unique ignoring case, and the first spelling seen is kept.
+INSERT INTO authors (id, created_at, name)
+VALUES ($1, $2, $3)
+ON CONFLICT (lower(name)) DO UPDATE SET name = authors.name
+RETURNING *;
+
+-- name: AddPostAuthor :exec
+INSERT INTO post_authors (post_id, author_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetAuthorsByPosts :many
+SELECT post_authors.post_id, authors.name FROM authors
+INNER JOIN post_authors ON authors.id = post_authors.author_id
+WHERE post_authors.post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY authors.name ASC;
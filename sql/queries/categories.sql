@@ -0,0 +1,17 @@
+-- name: UpsertCategory :one
+-- Names are unique ignoring case, and the first spelling seen is kept.
+INSERT INTO categories (id, created_at, name)
+VALUES ($1, $2, $3)
+ON CONFLICT (lower(name)) DO UPDATE SET name = categories.name
+RETURNING *;
+
+-- name: AddPostCategory :exec
+INSERT INTO post_categories (post_id, category_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetCategoriesByPosts :many
+SELECT post_categories.post_id, categories.name FROM categories
+INNER JOIN post_categories ON categories.id = post_categories.category_id
+WHERE post_categories.post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY categories.name ASC;
@@ -0,0 +1,12 @@
+-- name: CreateEnclosure :one
+INSERT INTO enclosures (
+  id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: GetEnclosuresByPosts :many
+SELECT * FROM enclosures
+WHERE post_id = ANY(sqlc.arg(post_ids)::uuid[])
+ORDER BY created_at ASC;
@@ -0,0 +1,23 @@
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+  id, feed_id, started_at, finished_at, status_code, body_bytes, items_seen, posts_created, posts_updated, error
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+);
+
+-- name: PruneFeedFetches :exec
+-- Keeps only the most recent fetches of a feed, so the history does not grow forever.
+DELETE FROM feed_fetches
+WHERE feed_id = sqlc.arg(feed_id)
+  AND id NOT IN (
+    SELECT id FROM feed_fetches
+    WHERE feed_id = sqlc.arg(feed_id)
+    ORDER BY started_at DESC
+    LIMIT sqlc.arg(max_fetches)
+  );
+
+-- name: GetFeedFetches :many
+SELECT * FROM feed_fetches
+WHERE feed_id = $1
+ORDER BY started_at DESC
+LIMIT $2;
@@ -10,5 +10,16 @@ VALUES
 -- name: GetFeedFollowForUser :many
 SELECT * FROM feed_follows WHERE user_id = $1;
 
+-- name: GetFeedFollowForFeed :one
+SELECT * FROM feed_follows WHERE user_id = $1 AND feed_id = $2;
+
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows WHERE id = $1 AND user_id = $2;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+  SET
+    feed_id = sqlc.arg(target_feed_id),
+    updated_at = NOW()
+  WHERE feed_id = sqlc.arg(source_feed_id)
+    AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = sqlc.arg(target_feed_id));
@@ -10,10 +10,15 @@ VALUES
 -- name: GetFeeds :many
 SELECT * FROM feeds;
 
+-- name: GetFeedByID :one
+SELECT * FROM feeds WHERE id = $1;
+
 -- name: GetNextFeedsToFetch :many
 SELECT * FROM feeds
-  ORDER BY last_fetched_at ASC NULLS FIRST
-  LIMIT $1;
+  WHERE disabled_at IS NULL
+    AND (next_fetch_at IS NULL OR next_fetch_at <= sqlc.arg(now)::timestamp)
+  ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+  LIMIT sqlc.arg(max_feeds);
 
 -- name: MarkFeedAsFetched :one
 UPDATE feeds
@@ -22,3 +27,75 @@ UPDATE feeds
     updated_at = NOW()
   WHERE id = $1
   RETURNING *;
+
+-- name: UpdateFeedCacheValidators :exec
+UPDATE feeds
+  SET
+    etag = $2,
+    last_modified = $3
+  WHERE id = $1;
+
+-- name: UpdateFeedMetadata :exec
+-- A missing image keeps the previously resolved one, and so does a missing favicon lookup time.
+UPDATE feeds
+  SET
+    title = sqlc.narg(title),
+    site_url = sqlc.narg(site_url),
+    description = sqlc.narg(description),
+    language = sqlc.narg(language),
+    image_url = COALESCE(sqlc.narg(image_url)::text, image_url),
+    favicon_checked_at = COALESCE(sqlc.narg(favicon_checked_at)::timestamp, favicon_checked_at)
+  WHERE id = sqlc.arg(id);
+
+-- name: ScheduleNextFetch :exec
+UPDATE feeds
+  SET
+    next_fetch_at = $2,
+    fetch_interval_seconds = $3
+  WHERE id = $1;
+
+-- name: MarkFeedFetchFailed :exec
+UPDATE feeds
+  SET
+    consecutive_failures = $2,
+    last_error = $3,
+    next_fetch_at = $4
+  WHERE id = $1;
+
+-- name: MarkFeedFetchSucceeded :exec
+UPDATE feeds
+  SET
+    consecutive_failures = 0,
+    last_error = NULL,
+    last_success_at = $2
+  WHERE id = $1;
+
+-- name: DisableFeed :exec
+UPDATE feeds
+  SET
+    disabled_at = $2,
+    updated_at = NOW()
+  WHERE id = $1;
+
+-- name: EnableFeed :one
+UPDATE feeds
+  SET
+    disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+  WHERE id = $1 AND user_id = $2
+  RETURNING *;
+
+-- name: GetFeedByURL :one
+SELECT * FROM feeds WHERE url = $1;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+  SET
+    url = $2,
+    updated_at = NOW()
+  WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1;
@@ -0,0 +1,14 @@
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (
+  id, created_at, post_id, title, url, description, content
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: GetPostRevisions :many
+-- Returns the revisions of a post, as long as the user follows the feed it belongs to.
+SELECT post_revisions.* FROM post_revisions
+INNER JOIN posts ON post_revisions.post_id = posts.id
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+WHERE post_revisions.post_id = sqlc.arg(post_id) AND feed_follows.user_id = sqlc.arg(user_id)
+ORDER BY post_revisions.created_at DESC;
@@ -1,17 +1,62 @@
 -- name: CreatePost :one
 INSERT INTO posts (
-  id, created_at, updated_at, title, url, description, published_at, feed_id
+  id, created_at, updated_at, title, url, description, published_at, feed_id, published_at_estimated, content, excerpt, guid,
+  content_hash
 ) VALUES (
-  $1, $2, $3, $4, $5, $6, $7, $8
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
 )
 RETURNING *;
 
--- name: FindPostByURL :one
-SELECT * FROM posts WHERE url = $1;
+-- name: FindFeedPost :one
+-- Posts are matched on their guid, falling back to the link for posts stored before the feed provided guids.
+-- Those posts have their link as guid, so posts of feeds using the same link for every item are not matched by link.
+SELECT * FROM posts
+WHERE feed_id = sqlc.arg(feed_id)
+  AND (guid = sqlc.arg(guid) OR (url = sqlc.narg(url) AND guid = url))
+ORDER BY guid = sqlc.arg(guid) DESC
+LIMIT 1;
+
+-- name: UpdatePostContent :one
+UPDATE posts
+  SET
+    title = sqlc.arg(title),
+    url = sqlc.arg(url),
+    guid = sqlc.arg(guid),
+    description = sqlc.narg(description),
+    content = sqlc.narg(content),
+    excerpt = sqlc.narg(excerpt),
+    content_hash = sqlc.arg(content_hash),
+    updated_at = NOW()
+  WHERE id = sqlc.arg(id)
+RETURNING *;
 
 -- name: GetPostsByUser :many
+-- Author and category filters are optional and case insensitive.
 SELECT posts.* FROM posts
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
-WHERE feed_follows.user_id = $1
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(author)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_authors
+    INNER JOIN authors ON post_authors.author_id = authors.id
+    WHERE post_authors.post_id = posts.id AND lower(authors.name) = lower(sqlc.narg(author))
+  ))
+  AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+    SELECT 1 FROM post_categories
+    INNER JOIN categories ON post_categories.category_id = categories.id
+    WHERE post_categories.post_id = posts.id AND lower(categories.name) = lower(sqlc.narg(category))
+  ))
 ORDER BY posts.published_at DESC
-LIMIT $2;
+LIMIT sqlc.arg(max_posts);
+
+-- name: DeleteDuplicatePosts :exec
+-- Deletes the posts of the source feed that are already present in the target feed.
+DELETE FROM posts
+WHERE feed_id = sqlc.arg(source_feed_id)
+  AND guid IN (SELECT guid FROM posts AS target WHERE target.feed_id = sqlc.arg(target_feed_id));
+
+-- name: MovePosts :exec
+UPDATE posts
+  SET
+    feed_id = sqlc.arg(target_feed_id),
+    updated_at = NOW()
+  WHERE feed_id = sqlc.arg(source_feed_id);
@@ -0,0 +1,57 @@
+-- name: UpsertWebSubSubscription :one
+-- Renewing a subscription to the same hub and topic keeps the current lease until the hub verifies the renewal,
+-- along with any previous denial, while moving to another hub or topic starts over.
+INSERT INTO websub_subscriptions (
+  feed_id, created_at, updated_at, hub_url, topic_url, secret, requested_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (feed_id) DO UPDATE
+  SET
+    updated_at = EXCLUDED.updated_at,
+    hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    secret = EXCLUDED.secret,
+    requested_at = EXCLUDED.requested_at,
+    lease_expires_at = CASE
+      WHEN websub_subscriptions.hub_url = EXCLUDED.hub_url AND websub_subscriptions.topic_url = EXCLUDED.topic_url
+        THEN websub_subscriptions.lease_expires_at
+    END,
+    denied_at = CASE
+      WHEN websub_subscriptions.hub_url = EXCLUDED.hub_url AND websub_subscriptions.topic_url = EXCLUDED.topic_url
+        THEN websub_subscriptions.denied_at
+    END
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT * FROM websub_subscriptions WHERE feed_id = $1;
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+  SET
+    lease_expires_at = $2,
+    requested_at = NULL,
+    denied_at = NULL,
+    updated_at = NOW()
+  WHERE feed_id = $1;
+
+-- name: DenyWebSubSubscription :exec
+-- Denied subscriptions are kept, so the crawler knows not to request them again right away.
+UPDATE websub_subscriptions
+  SET
+    lease_expires_at = NULL,
+    requested_at = NULL,
+    denied_at = NOW(),
+    updated_at = NOW()
+  WHERE feed_id = $1;
+
+-- name: ClearWebSubSubscriptionRequest :exec
+-- Requests the hub did not accept are never verified, so they no longer count as pending.
+UPDATE websub_subscriptions
+  SET
+    requested_at = NULL,
+    updated_at = NOW()
+  WHERE feed_id = $1;
+
+-- name: DeleteWebSubSubscription :exec
+DELETE FROM websub_subscriptions WHERE feed_id = $1;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN guid TEXT;
+UPDATE posts SET guid = url;
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+-- Posts are identified per feed, so the same article can be syndicated by different feeds
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+CREATE UNIQUE INDEX posts_feed_id_guid_idx ON posts(feed_id, guid);
+CREATE INDEX posts_feed_id_url_idx ON posts(feed_id, url);
+
+-- +goose Down
+DROP INDEX posts_feed_id_url_idx;
+DROP INDEX posts_feed_id_guid_idx;
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+ALTER TABLE posts DROP COLUMN guid;
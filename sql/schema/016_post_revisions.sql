@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+
+-- Previous versions of posts edited upstream, recorded when a fetch finds new content
+CREATE TABLE post_revisions (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  title TEXT NOT NULL,
+  url TEXT NOT NULL,
+  description TEXT,
+  content TEXT
+);
+CREATE INDEX post_revisions_post_id_idx ON post_revisions(post_id);
+
+-- +goose Down
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN content_hash;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE authors (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE post_authors (
+  post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  author_id UUID NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+  PRIMARY KEY(post_id, author_id)
+);
+CREATE INDEX post_authors_author_id_idx ON post_authors(author_id);
+
+CREATE TABLE categories (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE post_categories (
+  post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+  category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+  PRIMARY KEY(post_id, category_id)
+);
+CREATE INDEX post_categories_category_id_idx ON post_categories(category_id);
+
+-- +goose Down
+DROP TABLE post_categories;
+DROP TABLE categories;
+DROP TABLE post_authors;
+DROP TABLE authors;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- WebSub subscriptions, through which hubs push new content for feeds instead of waiting for the next poll
+CREATE TABLE websub_subscriptions (
+  feed_id UUID PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  hub_url TEXT NOT NULL,
+  topic_url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  -- Set once the hub verifies the subscription, the feed is polled again after it expires
+  lease_expires_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- History of fetch attempts, kept so feed owners can find out why posts are missing
+CREATE TABLE feed_fetches (
+  id UUID PRIMARY KEY,
+  feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP NOT NULL,
+  -- Null when the server could not be reached
+  status_code INTEGER,
+  body_bytes BIGINT NOT NULL,
+  items_seen INTEGER NOT NULL,
+  posts_created INTEGER NOT NULL,
+  posts_updated INTEGER NOT NULL,
+  error TEXT
+);
+CREATE INDEX feed_fetches_feed_id_started_at_idx ON feed_fetches(feed_id, started_at DESC);
+
+-- +goose Down
+DROP TABLE feed_fetches;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- Authors and categories are filtered ignoring case, so names differing only in case are merged into the oldest one
+INSERT INTO post_authors (post_id, author_id)
+SELECT post_authors.post_id, kept.id FROM post_authors
+INNER JOIN authors ON post_authors.author_id = authors.id
+INNER JOIN authors AS kept ON lower(kept.name) = lower(authors.name) AND (kept.created_at, kept.id) < (authors.created_at, authors.id)
+ON CONFLICT DO NOTHING;
+DELETE FROM authors USING authors AS kept
+WHERE lower(kept.name) = lower(authors.name) AND (kept.created_at, kept.id) < (authors.created_at, authors.id);
+ALTER TABLE authors DROP CONSTRAINT authors_name_key;
+CREATE UNIQUE INDEX authors_lower_name_idx ON authors(lower(name));
+
+INSERT INTO post_categories (post_id, category_id)
+SELECT post_categories.post_id, kept.id FROM post_categories
+INNER JOIN categories ON post_categories.category_id = categories.id
+INNER JOIN categories AS kept ON lower(kept.name) = lower(categories.name) AND (kept.created_at, kept.id) < (categories.created_at, categories.id)
+ON CONFLICT DO NOTHING;
+DELETE FROM categories USING categories AS kept
+WHERE lower(kept.name) = lower(categories.name) AND (kept.created_at, kept.id) < (categories.created_at, categories.id);
+ALTER TABLE categories DROP CONSTRAINT categories_name_key;
+CREATE UNIQUE INDEX categories_lower_name_idx ON categories(lower(name));
+
+-- +goose Down
+DROP INDEX categories_lower_name_idx;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
+DROP INDEX authors_lower_name_idx;
+ALTER TABLE authors ADD CONSTRAINT authors_name_key UNIQUE (name);
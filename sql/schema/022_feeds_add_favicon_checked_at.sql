@@ -0,0 +1,6 @@
+-- +goose Up
+-- Feeds without an image have the favicon of their site looked up, which is only retried once in a while
+ALTER TABLE feeds ADD COLUMN favicon_checked_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN favicon_checked_at;
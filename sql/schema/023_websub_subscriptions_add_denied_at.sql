@@ -0,0 +1,6 @@
+-- +goose Up
+-- Set when the hub denies the subscription, which is only requested again after a while
+ALTER TABLE websub_subscriptions ADD COLUMN denied_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE websub_subscriptions DROP COLUMN denied_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Set when a subscription request is sent to the hub, which may only verify the subscription while it is pending
+ALTER TABLE websub_subscriptions ADD COLUMN requested_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE websub_subscriptions DROP COLUMN requested_at;
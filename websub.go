@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deadpyxel/curator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// websubLeaseSeconds is the lease requested from hubs, which are free to grant a different one
+	websubLeaseSeconds = 7 * 24 * 60 * 60
+	// websubRenewMargin is how long before its lease expires a pushed feed is polled again, renewing the subscription.
+	// Subscription requests that are not verified within it are sent again.
+	websubRenewMargin = time.Hour
+	// websubDenialBackoff is how long the crawler waits before requesting a subscription the hub denied again
+	websubDenialBackoff = 7 * 24 * time.Hour
+)
+
+var errInvalidSignature = errors.New("invalid signature")
+
+// websubRequestPending reports if a subscription request was sent to the hub and not verified yet.
+// Requests that are not verified within websubRenewMargin are sent again, so older ones are no longer pending.
+func websubRequestPending(subscription database.WebsubSubscription, now time.Time) bool {
+	return subscription.RequestedAt.Valid && now.Sub(subscription.RequestedAt.Time) < websubRenewMargin
+}
+
+// websubLinks returns the hub and self links advertised by the channel, resolved against the feed URL.
+func (channel RSSChannel) websubLinks(feedURL *url.URL) (hub, topic string) {
+	for _, link := range channel.AtomLinks {
+		switch {
+		case hasToken(link.Rel, "hub") && hub == "":
+			hub = resolveWebURL(feedURL, link.Href)
+		case hasToken(link.Rel, "self") && topic == "":
+			topic = resolveWebURL(feedURL, link.Href)
+		}
+	}
+	return hub, topic
+}
+
+// websubClient subscribes feeds to the WebSub hubs they advertise, so new content is pushed instead of polled.
+type websubClient struct {
+	// callbackBaseURL is the public URL of the API, which hubs call back to verify subscriptions and push content
+	callbackBaseURL string
+	web             *webClient
+}
+
+func newWebSubClient(callbackBaseURL string, web *webClient) *websubClient {
+	return &websubClient{
+		callbackBaseURL: strings.TrimSuffix(callbackBaseURL, "/"),
+		web:             web,
+	}
+}
+
+// callbackURL returns the URL hubs call to verify the subscription of a feed and push its content.
+func (w *websubClient) callbackURL(feedID uuid.UUID) string {
+	return w.callbackBaseURL + "/v1/websub/" + feedID.String()
+}
+
+// subscribe sends a subscription request to the hub. Hubs verify requests asynchronously,
+// by calling the callback with a challenge, so the subscription is only active once that happens.
+func (w *websubClient) subscribe(ctx context.Context, subscription database.WebsubSubscription) error {
+	form := url.Values{
+		"hub.mode":          {"subscribe"},
+		"hub.topic":         {subscription.TopicUrl},
+		"hub.callback":      {w.callbackURL(subscription.FeedID)},
+		"hub.secret":        {subscription.Secret},
+		"hub.lease_seconds": {strconv.Itoa(websubLeaseSeconds)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.HubUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := w.web.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// verifySignature checks the X-Hub-Signature header of pushed content, an HMAC of the body keyed with the subscription secret.
+func verifySignature(header, secret string, body []byte) error {
+	method, signature, found := strings.Cut(header, "=")
+	if !found {
+		return fmt.Errorf("%w: missing signature", errInvalidSignature)
+	}
+
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return fmt.Errorf("%w: unsupported method %q", errInvalidSignature, method)
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("%w: signature does not match", errInvalidSignature)
+	}
+	return nil
+}
+
+// newWebSubSecret generates the secret a hub uses to sign the content it pushes.
+func newWebSubSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// updateWebSubSubscription keeps the subscription of a feed in line with the hub advertised by its channel.
+// Feeds that stop advertising a hub lose their subscription, so they are polled again right away.
+func (c *crawler) updateWebSubSubscription(feed database.Feed, channel RSSChannel) {
+	if c.websub == nil {
+		return
+	}
+	feedURL, err := url.Parse(feed.Url)
+	if err != nil {
+		return
+	}
+
+	hub, topic := channel.websubLinks(feedURL)
+	if hub == "" {
+		err := c.db.DeleteWebSubSubscription(context.Background(), feed.ID)
+		if err != nil {
+			logger.Error("Error deleting WebSub subscription", "feedID", feed.ID, "error", err)
+		}
+		return
+	}
+	// Feeds without a self link are identified by the URL they are fetched from
+	if topic == "" {
+		topic = feed.Url
+	}
+	c.subscribeWebSub(feed, hub, topic)
+}
+
+// renewWebSubSubscription renews the current subscription of a feed, for fetches that do not return the channel.
+func (c *crawler) renewWebSubSubscription(feed database.Feed) {
+	if c.websub == nil {
+		return
+	}
+	subscription, err := c.db.GetWebSubSubscription(context.Background(), feed.ID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("Error retrieving WebSub subscription", "feedID", feed.ID, "error", err)
+		}
+		return
+	}
+	c.subscribeWebSub(feed, subscription.HubUrl, subscription.TopicUrl)
+}
+
+// subscribeWebSub subscribes a feed to a hub, unless it is already subscribed or waiting for the hub to verify it.
+// Active subscriptions are renewed once their lease is about to expire, and denied ones once the denial backoff is over.
+func (c *crawler) subscribeWebSub(feed database.Feed, hub, topic string) {
+	ctx := context.Background()
+	existing, err := c.db.GetWebSubSubscription(ctx, feed.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Error retrieving WebSub subscription", "feedID", feed.ID, "error", err)
+		return
+	}
+
+	sameSubscription := err == nil && existing.HubUrl == hub && existing.TopicUrl == topic
+	secret := existing.Secret
+	if sameSubscription {
+		now := time.Now().UTC()
+		// Requesting a denied subscription again right away would have the hub deny it on every poll
+		if existing.DeniedAt.Valid && now.Sub(existing.DeniedAt.Time) < websubDenialBackoff {
+			return
+		}
+		if existing.LeaseExpiresAt.Valid && existing.LeaseExpiresAt.Time.Sub(now) > websubRenewMargin {
+			return
+		}
+		if !existing.LeaseExpiresAt.Valid && now.Sub(existing.UpdatedAt) < websubRenewMargin {
+			return
+		}
+	} else {
+		secret, err = newWebSubSecret()
+		if err != nil {
+			logger.Error("Error generating WebSub secret", "feedID", feed.ID, "error", err)
+			return
+		}
+	}
+
+	subscription, err := c.db.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+		FeedID:    feed.ID,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		HubUrl:    hub,
+		TopicUrl:  topic,
+		Secret:    secret,
+		// The request is marked as pending before it is sent, since hubs may verify it before answering
+		RequestedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		logger.Error("Error storing WebSub subscription", "feedID", feed.ID, "error", err)
+		return
+	}
+	if err := c.websub.subscribe(ctx, subscription); err != nil {
+		logger.Warn("WebSub subscription request failed", "feedID", feed.ID, "hub", hub, "error", err)
+		if err := c.db.ClearWebSubSubscriptionRequest(ctx, feed.ID); err != nil {
+			logger.Error("Error clearing WebSub subscription request", "feedID", feed.ID, "error", err)
+		}
+		return
+	}
+	logger.Info("WebSub subscription requested", "feedID", feed.ID, "hub", hub, "topic", topic)
+}
+
+// websubRenewal returns when a feed pushed through WebSub should be polled again, to renew its subscription.
+// ok is false when the feed has no active subscription, in which case it is polled as usual.
+func (c *crawler) websubRenewal(feedID uuid.UUID) (renewAt time.Time, ok bool) {
+	if c.websub == nil {
+		return time.Time{}, false
+	}
+	subscription, err := c.db.GetWebSubSubscription(context.Background(), feedID)
+	if err != nil || !subscription.LeaseExpiresAt.Valid {
+		return time.Time{}, false
+	}
+	renewAt = subscription.LeaseExpiresAt.Time.Add(-websubRenewMargin)
+	return renewAt, renewAt.After(time.Now().UTC())
+}
+
+// ingestPushedFeed stores the items of a feed document pushed by a hub, through the same path as polled feeds.
+func (c *crawler) ingestPushedFeed(feed database.Feed, contentType string, body []byte) error {
+	rssFeed, err := parseFeed(contentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	summary := c.ingestItems(feed, rssFeed.Channel.Item, time.Now().UTC())
+	logger.Info("Pushed feed content ingested", "feedID", feed.ID, "numPosts", len(rssFeed.Channel.Item), "newPosts", summary.NewPosts, "updatedPosts", summary.UpdatedPosts)
+	return nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deadpyxel/curator/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestWebSubLinks(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentType   string
+		document      string
+		expectedHub   string
+		expectedTopic string
+	}{
+		{
+			name:        "RSS with Atom links",
+			contentType: "application/rss+xml",
+			document: `<rss xmlns:atom="http://www.w3.org/2005/Atom"><channel>
+  <link>https://example.com/</link>
+  <atom:link rel="hub" href="https://hub.example.com/"/>
+  <atom:link rel="self" href="/rss.xml" type="application/rss+xml"/>
+</channel></rss>`,
+			expectedHub:   "https://hub.example.com/",
+			expectedTopic: "https://example.com/rss.xml",
+		},
+		{
+			name:        "Atom feed",
+			contentType: "application/atom+xml",
+			document: `<feed xmlns="http://www.w3.org/2005/Atom">
+  <link rel="self" href="https://example.com/atom.xml"/>
+  <link rel="hub" href="https://hub.example.com/"/>
+</feed>`,
+			expectedHub:   "https://hub.example.com/",
+			expectedTopic: "https://example.com/atom.xml",
+		},
+		{
+			name:          "JSON Feed hubs",
+			contentType:   "application/feed+json",
+			document:      `{"version": "https://jsonfeed.org/version/1.1", "feed_url": "https://example.com/feed.json", "hubs": [{"type": "rssCloud", "url": "https://cloud.example.com/"}, {"type": "WebSub", "url": "https://hub.example.com/"}], "items": []}`,
+			expectedHub:   "https://hub.example.com/",
+			expectedTopic: "https://example.com/feed.json",
+		},
+		{
+			name:        "No hub",
+			contentType: "application/rss+xml",
+			document:    `<rss><channel><link>https://example.com/</link></channel></rss>`,
+		},
+	}
+
+	feedURL, _ := url.Parse("https://example.com/feed")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, strings.NewReader(tt.document))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			hub, topic := feed.Channel.websubLinks(feedURL)
+			if hub != tt.expectedHub || topic != tt.expectedTopic {
+				t.Errorf("Expected hub %q and topic %q, got %q and %q", tt.expectedHub, tt.expectedTopic, hub, topic)
+			}
+		})
+	}
+
+	t.Run("Atom links do not replace the channel link", func(t *testing.T) {
+		feed, err := parseFeed(tests[0].contentType, strings.NewReader(tests[0].document))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if feed.Channel.Link != "https://example.com/" {
+			t.Errorf("Expected channel link %q, got %q", "https://example.com/", feed.Channel.Link)
+		}
+	})
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "secret"
+	body := []byte(rssDocument)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		header    string
+		expectErr bool
+	}{
+		{"Valid signature", "sha256=" + signature, false},
+		{"Uppercase method", "SHA256=" + signature, false},
+		{"Wrong signature", "sha256=" + strings.Repeat("0", len(signature)), true},
+		{"Wrong method", "sha1=" + signature, true},
+		{"Unsupported method", "md5=" + signature, true},
+		{"Not hex encoded", "sha256=signature", true},
+		{"Missing signature", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.header, secret, body)
+			if tt.expectErr && !errors.Is(err, errInvalidSignature) {
+				t.Errorf("Expected invalid signature error, got %v", err)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebSubSubscribe(t *testing.T) {
+	var form url.Values
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		if form.Get("hub.topic") == "https://example.com/rejected.xml" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	client := newWebSubClient("https://curator.example.com/", newHTTPFetcher(defaultFetcherConfig()).webClient())
+	subscription := database.WebsubSubscription{
+		FeedID:   uuid.New(),
+		HubUrl:   hub.URL,
+		TopicUrl: "https://example.com/rss.xml",
+		Secret:   "secret",
+	}
+
+	if err := client.subscribe(context.Background(), subscription); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := map[string]string{
+		"hub.mode":     "subscribe",
+		"hub.topic":    subscription.TopicUrl,
+		"hub.callback": "https://curator.example.com/v1/websub/" + subscription.FeedID.String(),
+		"hub.secret":   "secret",
+	}
+	for key, value := range expected {
+		if form.Get(key) != value {
+			t.Errorf("Expected %s %q, got %q", key, value, form.Get(key))
+		}
+	}
+
+	subscription.TopicUrl = "https://example.com/rejected.xml"
+	if err := client.subscribe(context.Background(), subscription); err == nil {
+		t.Error("Expected an error when the hub rejects the request")
+	}
+}
+
+func TestWebSubDenial(t *testing.T) {
+	conn := newTestDB(t)
+	requests := 0
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	websub := newWebSubClient("https://curator.example.com/", newHTTPFetcher(defaultFetcherConfig()).webClient())
+	c := newCrawler(conn, &fakeFetcher{}, nil, websub, 0)
+	apiCfg := apiConfig{DB: c.db, Crawler: c}
+	feed := createTestFeed(t, c.db)
+	const topic = "https://example.com/rss.xml"
+
+	c.subscribeWebSub(feed, hub.URL, topic)
+	if requests != 1 {
+		t.Fatalf("Expected the subscription to be requested, got %d requests", requests)
+	}
+
+	deny := func(topic string) int {
+		query := url.Values{"hub.mode": {"denied"}, "hub.topic": {topic}, "hub.reason": {"Not allowed"}}
+		req := httptest.NewRequest(http.MethodGet, "/v1/websub/"+feed.ID.String()+"?"+query.Encode(), nil)
+		req.SetPathValue("feedID", feed.ID.String())
+		rr := httptest.NewRecorder()
+		apiCfg.handlerWebSubVerify(rr, req)
+		return rr.Code
+	}
+
+	if code := deny("https://attacker.example.com/rss.xml"); code != http.StatusNotFound {
+		t.Errorf("Expected denial of another topic to be refused, got status %d", code)
+	}
+	subscription, err := c.db.GetWebSubSubscription(context.Background(), feed.ID)
+	if err != nil || subscription.DeniedAt.Valid {
+		t.Fatalf("Expected the subscription to be left untouched, got %+v, %v", subscription, err)
+	}
+
+	if code := deny(topic); code != http.StatusOK {
+		t.Errorf("Expected denial to be acknowledged, got status %d", code)
+	}
+	subscription, err = c.db.GetWebSubSubscription(context.Background(), feed.ID)
+	if err != nil || !subscription.DeniedAt.Valid {
+		t.Fatalf("Expected the denial to be recorded, got %+v, %v", subscription, err)
+	}
+
+	// Polling the feed again must not request the denied subscription right away
+	c.subscribeWebSub(feed, hub.URL, topic)
+	if requests != 1 {
+		t.Errorf("Expected denied subscription not to be requested again, got %d requests", requests)
+	}
+}
+
+func TestWebSubRequestPending(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		requestedAt sql.NullTime
+		expected    bool
+	}{
+		{"Never requested", sql.NullTime{}, false},
+		{"Requested just now", sql.NullTime{Time: now.Add(-time.Minute), Valid: true}, true},
+		{"Request sent again since", sql.NullTime{Time: now.Add(-websubRenewMargin), Valid: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscription := database.WebsubSubscription{RequestedAt: tt.requestedAt}
+			if result := websubRequestPending(subscription, now); result != tt.expected {
+				t.Errorf("Expected %t, got %t", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestWebSubVerifyUnsolicited(t *testing.T) {
+	conn := newTestDB(t)
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hub.Close()
+
+	websub := newWebSubClient("https://curator.example.com/", newHTTPFetcher(defaultFetcherConfig()).webClient())
+	c := newCrawler(conn, &fakeFetcher{}, nil, websub, 0)
+	apiCfg := apiConfig{DB: c.db, Crawler: c}
+	feed := createTestFeed(t, c.db)
+	const topic = "https://example.com/rss.xml"
+
+	confirm := func() int {
+		query := url.Values{
+			"hub.mode":          {"subscribe"},
+			"hub.topic":         {topic},
+			"hub.challenge":     {"challenge"},
+			"hub.lease_seconds": {"315360000"},
+		}
+		req := httptest.NewRequest(http.MethodGet, "/v1/websub/"+feed.ID.String()+"?"+query.Encode(), nil)
+		req.SetPathValue("feedID", feed.ID.String())
+		rr := httptest.NewRecorder()
+		apiCfg.handlerWebSubVerify(rr, req)
+		return rr.Code
+	}
+
+	// A subscription stored without a request being sent, like one whose request the hub already verified
+	_, err := c.db.UpsertWebSubSubscription(context.Background(), database.UpsertWebSubSubscriptionParams{
+		FeedID:    feed.ID,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		HubUrl:    hub.URL,
+		TopicUrl:  topic,
+		Secret:    "secret",
+	})
+	if err != nil {
+		t.Fatalf("Could not store subscription: %v", err)
+	}
+	if code := confirm(); code != http.StatusNotFound {
+		t.Errorf("Expected unsolicited confirmation to be refused, got status %d", code)
+	}
+	subscription, err := c.db.GetWebSubSubscription(context.Background(), feed.ID)
+	if err != nil || subscription.LeaseExpiresAt.Valid {
+		t.Fatalf("Expected the subscription to stay inactive, got %+v, %v", subscription, err)
+	}
+
+	c.subscribeWebSub(feed, hub.URL, topic)
+	if code := confirm(); code != http.StatusOK {
+		t.Errorf("Expected pending request to be verified, got status %d", code)
+	}
+	subscription, err = c.db.GetWebSubSubscription(context.Background(), feed.ID)
+	if err != nil || !subscription.LeaseExpiresAt.Valid {
+		t.Fatalf("Expected the subscription to be active, got %+v, %v", subscription, err)
+	}
+	if maxExpiry := time.Now().UTC().Add(websubLeaseSeconds * time.Second); subscription.LeaseExpiresAt.Time.After(maxExpiry) {
+		t.Errorf("Expected the lease to be capped at %v, got %v", maxExpiry, subscription.LeaseExpiresAt.Time)
+	}
+
+	// The request was verified, so confirming it again is refused
+	if code := confirm(); code != http.StatusNotFound {
+		t.Errorf("Expected a second confirmation to be refused, got status %d", code)
+	}
+}